@@ -0,0 +1,156 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// AuthRequest is a change of an entity presented to an Authorizer before it is made.
+type AuthRequest struct {
+	Workflow   string
+	EntityType string
+	EntityID   string
+	Entity     any
+	// Actor is the actor given to SetActorSm.
+	Actor string
+	Kind  TransitionEventKind
+	From  types.TxState
+	// Event is empty unless Kind is TransitionedEventKind.
+	Event types.TxEvent
+	// Assignee is empty unless Kind is AssignedEventKind.
+	Assignee string
+	// UndoState is the pending state restored by a change of kind UndoneEventKind, see UndoSm.
+	// Undoing an approval requires the permission to approve from UndoState.
+	UndoState types.TxState
+}
+
+// Authorizer allows a change by returning nil.
+type Authorizer interface {
+	Authorize(r AuthRequest) error
+}
+
+// AuthorizerFunc is a function used as an Authorizer.
+type AuthorizerFunc func(r AuthRequest) error
+
+func (f AuthorizerFunc) Authorize(r AuthRequest) error {
+	return f(r)
+}
+
+// WithAuthorizer makes a check every change of entities following the workflow before it is made.
+// A change rejected by a fails with ErrForbidden and leaves the entity untouched.
+// Authorizers are called in the order they are added, after the permissions of the workflow.
+func WithAuthorizer(a Authorizer) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.authorizers = append(o.authorizers, a)
+	}
+}
+
+// Permission allows actors having one of Roles to fire Event in State, or in any state if State is empty.
+type Permission struct {
+	State types.TxState
+	Event types.TxEvent
+	Roles []string
+}
+
+// WithPermissions restricts events to the roles of perms. An event no permission mentions for the current state
+// may be fired by anyone. Roles of an actor come from WithRoles.
+func WithPermissions(perms ...Permission) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.permissions = append(o.permissions, perms...)
+	}
+}
+
+// RoleResolver returns the roles of r.Actor, which may depend on r.Entity, e.g. "requester" for its owner.
+type RoleResolver func(r AuthRequest) []string
+
+// WithRoles makes fn give the roles of actors to the permissions of the workflow.
+// Without it, actors have no role.
+func WithRoles(fn RoleResolver) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.roles = fn
+	}
+}
+
+func validatePermissions(w *internal.TxWorkflow, perms []Permission) error {
+	events := make(map[types.TxEvent]bool, len(w.Events))
+	for _, ev := range w.Events {
+		events[ev] = true
+	}
+	for _, p := range perms {
+		if p.State != "" && !w.HasState(p.State) {
+			return fmt.Errorf("state of permission is not declared: %s", p.State)
+		}
+		if !events[p.Event] {
+			return fmt.Errorf("event of permission is not declared: %q", p.Event)
+		}
+		if len(p.Roles) == 0 {
+			return fmt.Errorf("permission to %s in %q has no role", p.Event, p.State)
+		}
+	}
+	return nil
+}
+
+// authorize returns an error wrapping ErrForbidden if the change o of an entity is not allowed by w.
+func (w *Workflow) authorize(host, sm any, o TransitionOutcome) error {
+	if len(w.opts.permissions) == 0 && len(w.opts.authorizers) == 0 {
+		return nil
+	}
+	r := AuthRequest{Workflow: w.Name(), Actor: o.Actor, Kind: o.Kind, From: o.From, Event: o.Event, Assignee: o.Assignee}
+	r.Entity, r.EntityType, r.EntityID = w.entityOf(host, sm)
+	if h, ok := host.(interface{ undo() *Undo }); ok && o.Kind == UndoneEventKind {
+		r.UndoState = h.undo().UndoState
+	}
+
+	if err := w.checkPermissions(r); err != nil {
+		return err
+	}
+	for _, a := range w.opts.authorizers {
+		if err := a.Authorize(r); err != nil {
+			if errors.Is(err, ErrForbidden) {
+				return err
+			}
+			return fmt.Errorf("%w: %w", ErrForbidden, err)
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) checkPermissions(r AuthRequest) error {
+	ev, from := r.Event, r.From
+	switch {
+	case r.Kind == TransitionedEventKind:
+	case r.Kind == UndoneEventKind && r.UndoState != "":
+		// an approval is undone by those who may approve it
+		ev, from = types.ApproveTxEvent, r.UndoState
+	default:
+		return nil
+	}
+	var allowed []string
+	restricted := false
+	for _, p := range w.opts.permissions {
+		if p.Event == ev && (p.State == "" || p.State == from) {
+			restricted = true
+			allowed = append(allowed, p.Roles...)
+		}
+	}
+	if !restricted {
+		return nil
+	}
+
+	if w.opts.roles != nil {
+		for _, role := range w.opts.roles(r) {
+			for _, v := range allowed {
+				if role == v {
+					return nil
+				}
+			}
+		}
+	}
+	if r.Kind == UndoneEventKind {
+		return fmt.Errorf("%w: %q may not undo %s from %s", ErrForbidden, r.Actor, ev, from)
+	}
+	return fmt.Errorf("%w: %q may not %s in %s", ErrForbidden, r.Actor, ev, from)
+}
@@ -0,0 +1,204 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+type authItem struct {
+	ID    string
+	Owner string
+	TxStateMachineClock
+	Undo
+}
+
+func (i *authItem) EntityID() string {
+	return i.ID
+}
+
+var authUsers = map[string][]string{"bob": {"manager"}}
+
+// authRoles gives the owner of an item the requester role.
+func authRoles(r AuthRequest) []string {
+	roles := authUsers[r.Actor]
+	if i, ok := r.Entity.(*authItem); ok && i.Owner == r.Actor {
+		roles = append(roles, "requester")
+	}
+	return roles
+}
+
+func newAuthItem(t *testing.T, w *Workflow, s types.TxState, actor string) *authItem {
+	i := &authItem{ID: "i-1", Owner: "alice", TxStateMachineClock: TxStateMachineClock{State: s}}
+	require.Nil(t, i.SetWorkflowSm(w))
+	i.SetHostSm(i)
+	i.SetActorSm(actor)
+	return i
+}
+
+func Test_WithPermissions(t *testing.T) {
+	var got []TransitionOutcome
+	w, err := newEventSourcedWorkflow(t).With(
+		WithPermissions(
+			Permission{State: types.RemovePendingTxState, Event: types.ApproveTxEvent, Roles: []string{"manager"}},
+			Permission{State: types.PendingTxState, Event: types.CancelTxEvent, Roles: []string{"requester"}},
+		),
+		WithRoles(authRoles),
+		WithObserver(TransitionObserverFunc(func(o TransitionOutcome) { got = append(got, o) })),
+	)
+	require.Nil(t, err)
+
+	i := newAuthItem(t, w, types.RemovePendingTxState, "alice")
+	err = i.ApproveSm()
+	require.ErrorIs(t, err, ErrForbidden)
+	require.Equal(t, ForbiddenError, ErrorKindOf(err))
+	require.EqualValues(t, types.RemovePendingTxState, i.State)
+	require.Zero(t, i.Version)
+	require.Nil(t, i.UpdatedAt)
+	require.Empty(t, i.UncommittedEventsSm())
+	require.Equal(t, ForbiddenError, got[0].ErrorKind)
+
+	// events without a permission are open to anyone
+	require.Nil(t, i.CancelSm())
+	require.EqualValues(t, types.ActiveTxState, i.State)
+
+	i = newAuthItem(t, w, types.RemovePendingTxState, "bob")
+	require.Nil(t, i.ApproveSm())
+	require.EqualValues(t, types.RemovedTxState, i.State)
+
+	i = newAuthItem(t, w, types.PendingTxState, "bob")
+	require.ErrorIs(t, i.CancelSm(), ErrForbidden)
+	i = newAuthItem(t, w, types.PendingTxState, "alice")
+	require.Nil(t, i.CancelSm())
+	require.EqualValues(t, types.CanceledTxState, i.State)
+}
+
+func Test_WithPermissions_without_roles(t *testing.T) {
+	w, err := DefaultWorkflow().With(WithPermissions(Permission{Event: types.ApproveTxEvent, Roles: []string{"manager"}}), WithUnrestrictedForce())
+	require.Nil(t, err)
+
+	i := newAuthItem(t, w, types.PendingTxState, "bob")
+	require.ErrorIs(t, i.ApproveSm(), ErrForbidden)
+	// forcing is left to the force policy
+	require.Nil(t, i.ForceStateSm(types.ActiveTxState))
+}
+
+func Test_WithPermissions_invalid(t *testing.T) {
+	for _, p := range []Permission{
+		{State: "unknown", Event: types.ApproveTxEvent, Roles: []string{"manager"}},
+		{Event: "unknown", Roles: []string{"manager"}},
+		{Event: types.ApproveTxEvent},
+	} {
+		_, err := DefaultWorkflow().With(WithPermissions(p))
+		require.NotNil(t, err, p)
+	}
+}
+
+func Test_WithAuthorizer(t *testing.T) {
+	frozen := errors.New("frozen")
+	var requests []AuthRequest
+	w, err := DefaultWorkflow().With(WithAuthorizer(AuthorizerFunc(func(r AuthRequest) error {
+		requests = append(requests, r)
+		if r.Actor == "mallory" {
+			return frozen
+		}
+		return nil
+	})))
+	require.Nil(t, err)
+
+	i := newAuthItem(t, w, types.PendingTxState, "mallory")
+	err = i.ApproveSm()
+	require.ErrorIs(t, err, ErrForbidden)
+	require.ErrorIs(t, err, frozen)
+	i.SetActorSm("mallory")
+	require.ErrorIs(t, i.ForceStateSm(types.ActiveTxState), ErrForbidden)
+	require.EqualValues(t, types.PendingTxState, i.State)
+
+	i.SetActorSm("alice")
+	require.Nil(t, i.ApproveSm())
+
+	require.Len(t, requests, 3)
+	require.Equal(t, AuthRequest{
+		Workflow: "tx", EntityType: "authItem", EntityID: "i-1", Entity: i, Actor: "mallory",
+		Kind: TransitionedEventKind, From: types.PendingTxState, Event: types.ApproveTxEvent,
+	}, requests[0])
+	require.Equal(t, ForcedEventKind, requests[1].Kind)
+}
+
+func Test_LoadWorkflow_permissions(t *testing.T) {
+	w, err := LoadWorkflow(strings.NewReader(`
+name: ticket
+initial: open
+states:
+  - name: open
+  - name: closed
+    terminal: true
+events: [close]
+transitions:
+  - from: open
+    event: close
+    to: closed
+permissions:
+  - state: open
+    event: close
+    roles: [manager, admin]
+`), WithRoles(authRoles))
+	require.Nil(t, err)
+
+	i := newAuthItem(t, w, "open", "alice")
+	require.ErrorIs(t, i.FireSm("close"), ErrForbidden)
+	i.SetActorSm("bob")
+	require.Nil(t, i.FireSm("close"))
+
+	_, err = LoadWorkflow(strings.NewReader(`
+name: ticket
+initial: open
+states: [{name: open}]
+events: [close]
+transitions: []
+permissions:
+  - event: close
+    roles: []
+`))
+	require.EqualError(t, err, "line 8: at least one role is required")
+}
+
+func Test_WithPermissions_undo(t *testing.T) {
+	var got []AuthRequest
+	w, err := DefaultWorkflow().With(
+		WithUndoWindow(time.Hour),
+		WithPermissions(Permission{State: types.RemovePendingTxState, Event: types.ApproveTxEvent, Roles: []string{"manager"}}),
+		WithRoles(authRoles),
+		WithAuthorizer(AuthorizerFunc(func(r AuthRequest) error {
+			got = append(got, r)
+			return nil
+		})),
+	)
+	require.Nil(t, err)
+
+	i := newAuthItem(t, w, types.RemovePendingTxState, "bob")
+	require.Nil(t, i.ApproveSm())
+	require.EqualValues(t, types.RemovedTxState, i.State)
+
+	// undoing the approval requires the permission to approve
+	i.SetActorSm("alice")
+	err = i.UndoSm()
+	require.ErrorIs(t, err, ErrForbidden)
+	require.EqualValues(t, types.RemovedTxState, i.State)
+
+	i.SetActorSm("bob")
+	require.Nil(t, i.UndoSm())
+	require.EqualValues(t, types.RemovePendingTxState, i.State)
+	require.Equal(t, UndoneEventKind, got[1].Kind)
+	require.EqualValues(t, types.RemovePendingTxState, got[1].UndoState)
+
+	// approvals open to anyone are undone by anyone
+	i = newAuthItem(t, w, types.PendingTxState, "alice")
+	require.Nil(t, i.ApproveSm())
+	require.Nil(t, i.UndoSm())
+	require.EqualValues(t, types.PendingTxState, i.State)
+}
@@ -0,0 +1,331 @@
+package state
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wonksing/state/types"
+)
+
+var defaultBus atomic.Pointer[Bus]
+
+func init() {
+	defaultBus.Store(NewBus())
+}
+
+// DefaultBus returns the bus transitions are published to unless their workflow uses WithBus.
+func DefaultBus() *Bus {
+	return defaultBus.Load()
+}
+
+// SetDefaultBus replaces the bus returned by DefaultBus for the whole process.
+func SetDefaultBus(b *Bus) error {
+	if b == nil {
+		return errors.New("bus is nil")
+	}
+	defaultBus.Store(b)
+	return nil
+}
+
+// Transition is a change of an entity published to a Bus.
+type Transition struct {
+	// EntityType and EntityID name the host given to SetHostSm, see EntityTypeOf and EntityIDOf.
+	// Without a host, EntityType is the name of the workflow.
+	EntityType string
+	EntityID   string
+	// Entity is the host given to SetHostSm, or the embedded state machine.
+	// It is nil for asynchronous subscribers, which run after the entity may have changed again.
+	Entity   any
+	Workflow string
+	// Actor is the actor given to SetActorSm.
+	Actor string
+
+	TransitionEvent
+}
+
+// Filter selects the transitions a subscriber receives. Empty fields match anything.
+// From and To match changes of state only, not changes keeping it like those of kind AssignedEventKind.
+type Filter struct {
+	EntityType string
+	Kind       TransitionEventKind
+	Event      types.TxEvent
+	From       types.TxState
+	To         types.TxState
+}
+
+func (f Filter) match(t Transition) bool {
+	return (f.EntityType == "" || f.EntityType == t.EntityType) &&
+		(f.Kind == "" || f.Kind == t.Kind) &&
+		(f.Event == "" || f.Event == t.Event) &&
+		(f.From == "" || (f.From == t.From && t.From != t.To)) &&
+		(f.To == "" || (f.To == t.To && t.From != t.To))
+}
+
+// Handler receives transitions. It must not transition the entity it receives.
+type Handler func(t Transition)
+
+// BackpressurePolicy decides what an asynchronous subscription does when its buffer is full.
+type BackpressurePolicy int
+
+const (
+	// Block makes the publisher wait until the buffer has room.
+	Block BackpressurePolicy = iota
+	// DropNewest drops the transition being published.
+	DropNewest
+	// DropOldest drops the oldest buffered transition to make room.
+	DropOldest
+)
+
+type subscribeOptions struct {
+	async  bool
+	buffer int
+	policy BackpressurePolicy
+}
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(o *subscribeOptions)
+
+// Async delivers transitions on a goroutine of the subscription through a buffer of size transitions,
+// applying policy when the buffer is full. Their Entity is nil, so that the handler does not race with
+// the owner of the entity; the transition itself carries its state, version and time.
+// Without it, the handler is called by the publisher before the transition method returns.
+func Async(size int, policy BackpressurePolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.async = true
+		o.buffer = size
+		o.policy = policy
+	}
+}
+
+// Subscription is a handler registered on a Bus.
+type Subscription struct {
+	bus     *Bus
+	filter  Filter
+	handler Handler
+	opts    subscribeOptions
+
+	ch       chan Transition
+	done     chan struct{}
+	finished chan struct{}
+	once     sync.Once
+
+	dropped atomic.Uint64
+	panics  atomic.Uint64
+}
+
+// Dropped returns how many transitions were dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Panics returns how many times the handler panicked.
+func (s *Subscription) Panics() uint64 {
+	return s.panics.Load()
+}
+
+// Unsubscribe removes s from its bus.
+// Buffered transitions are delivered before it returns; later ones are not.
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+	s.stop()
+}
+
+func (s *Subscription) stop() {
+	s.once.Do(func() {
+		if !s.opts.async {
+			return
+		}
+		close(s.done)
+		<-s.finished
+	})
+}
+
+func (s *Subscription) deliver(t Transition) {
+	if !s.opts.async {
+		s.call(t)
+		return
+	}
+	t.Entity = nil
+
+	switch s.opts.policy {
+	case DropNewest:
+		select {
+		case s.ch <- t:
+		case <-s.done:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- t:
+				return
+			case <-s.done:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.ch <- t:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *Subscription) run() {
+	defer close(s.finished)
+	for {
+		select {
+		case t := <-s.ch:
+			s.call(t)
+		case <-s.done:
+			for {
+				select {
+				case t := <-s.ch:
+					s.call(t)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// call runs the handler, so that a panic affects neither the publisher nor other subscribers.
+func (s *Subscription) call(t Transition) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.panics.Add(1)
+			if s.bus.onPanic != nil {
+				s.bus.onPanic(t, r)
+			}
+		}
+	}()
+	s.handler(t)
+}
+
+// Bus delivers transitions to the subscribers whose filter matches them.
+// It is safe for concurrent use.
+type Bus struct {
+	mu      sync.RWMutex
+	subs    []*Subscription
+	closed  bool
+	onPanic func(t Transition, recovered any)
+}
+
+// BusOption configures a Bus.
+type BusOption func(b *Bus)
+
+// WithPanicHandler calls fn with the value recovered from a panicking handler.
+func WithPanicHandler(fn func(t Transition, recovered any)) BusOption {
+	return func(b *Bus) {
+		b.onPanic = fn
+	}
+}
+
+func NewBus(opts ...BusOption) *Bus {
+	b := &Bus{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Subscribe registers h for the transitions f matches.
+func (b *Bus) Subscribe(f Filter, h Handler, opts ...SubscribeOption) (*Subscription, error) {
+	if h == nil {
+		return nil, errors.New("handler is nil")
+	}
+	s := &Subscription{bus: b, filter: f, handler: h}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	if s.opts.async {
+		if s.opts.buffer < 1 {
+			return nil, errors.New("buffer size must be positive")
+		}
+		s.ch = make(chan Transition, s.opts.buffer)
+		s.done = make(chan struct{})
+		s.finished = make(chan struct{})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, errors.New("bus is closed")
+	}
+	if s.opts.async {
+		go s.run()
+	}
+	b.subs = append(b.subs, s)
+	return s, nil
+}
+
+// Publish delivers t to the matching subscribers in the order they subscribed.
+// It does nothing once b is closed.
+func (b *Bus) Publish(t Transition) {
+	b.mu.RLock()
+	if b.closed || len(b.subs) == 0 {
+		b.mu.RUnlock()
+		return
+	}
+	subs := make([]*Subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		if s.filter.match(t) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		s.deliver(t)
+	}
+}
+
+// Close stops delivering new transitions and waits for asynchronous subscribers
+// to handle the transitions already buffered.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.stop()
+	}
+}
+
+func (b *Bus) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, v := range b.subs {
+		if v == s {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish sends ev of an entity following w to the bus of w.
+// host is the entity given to SetHostSm, or nil.
+func (w *Workflow) publish(host, sm any, actor string, ev TransitionEvent) {
+	b := w.Bus()
+	b.mu.RLock()
+	empty := len(b.subs) == 0
+	b.mu.RUnlock()
+	if empty {
+		return
+	}
+
+	t := Transition{Workflow: w.Name(), Actor: actor, TransitionEvent: ev}
+	t.Entity, t.EntityType, t.EntityID = w.entityOf(host, sm)
+	b.Publish(t)
+}
@@ -0,0 +1,170 @@
+package state
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+type busOrder struct {
+	ID string
+	TxStateMachineClock
+}
+
+func (o *busOrder) EntityID() string {
+	return o.ID
+}
+
+func newBusOrder(t *testing.T, b *Bus, id string) *busOrder {
+	w, err := DefaultWorkflow().With(WithBus(b))
+	require.Nil(t, err)
+	o := &busOrder{ID: id}
+	require.Nil(t, o.SetWorkflowSm(w))
+	o.SetHostSm(o)
+	return o
+}
+
+func Test_Bus_filters(t *testing.T) {
+	b := NewBus()
+	defer b.Close()
+
+	var entered, canceled, all []Transition
+	_, err := b.Subscribe(Filter{To: types.RemovePendingTxState}, func(t Transition) { entered = append(entered, t) })
+	require.Nil(t, err)
+	_, err = b.Subscribe(Filter{Event: types.CancelTxEvent}, func(t Transition) { canceled = append(canceled, t) })
+	require.Nil(t, err)
+	_, err = b.Subscribe(Filter{EntityType: "busOrder"}, func(t Transition) { all = append(all, t) })
+	require.Nil(t, err)
+
+	o := newBusOrder(t, b, "o-1")
+	require.Nil(t, o.PendingSm())
+	o.ResetTicked()
+	require.Nil(t, o.ApproveSm())
+	o.ResetTicked()
+	o.SetActorSm("bob")
+	require.Nil(t, o.RemovePendingSm())
+	require.Nil(t, o.CancelSm())
+	require.NotNil(t, o.CancelSm())
+
+	require.Len(t, entered, 1)
+	require.Equal(t, types.ActiveTxState, entered[0].From)
+	require.Equal(t, "o-1", entered[0].EntityID)
+	require.Equal(t, "bob", entered[0].Actor)
+	require.Equal(t, "tx", entered[0].Workflow)
+	require.Equal(t, uint64(3), entered[0].Version)
+	require.Same(t, o, entered[0].Entity)
+
+	require.Len(t, canceled, 1)
+	require.Equal(t, types.RemovePendingTxState, canceled[0].From)
+	require.Equal(t, types.ActiveTxState, canceled[0].To)
+	require.Len(t, all, 4)
+}
+
+func Test_Bus_without_host(t *testing.T) {
+	b := NewBus()
+	defer b.Close()
+
+	var got []Transition
+	_, err := b.Subscribe(Filter{Kind: ForcedEventKind}, func(t Transition) { got = append(got, t) })
+	require.Nil(t, err)
+
+	w, err := DefaultWorkflow().With(WithBus(b), WithUnrestrictedForce())
+	require.Nil(t, err)
+	e := &TxStateMachine{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.PendingSm())
+	require.Nil(t, e.ForceStateSm(types.InactiveTxState))
+
+	require.Len(t, got, 1)
+	require.Equal(t, "tx", got[0].EntityType)
+	require.Equal(t, "", got[0].EntityID)
+	require.Equal(t, types.InactiveTxState, got[0].To)
+}
+
+func Test_Bus_isolates_panics(t *testing.T) {
+	var recovered []any
+	b := NewBus(WithPanicHandler(func(t Transition, r any) { recovered = append(recovered, r) }))
+	defer b.Close()
+
+	s, err := b.Subscribe(Filter{}, func(t Transition) { panic("boom") })
+	require.Nil(t, err)
+	n := 0
+	_, err = b.Subscribe(Filter{}, func(t Transition) { n++ })
+	require.Nil(t, err)
+
+	o := newBusOrder(t, b, "o-1")
+	require.Nil(t, o.PendingSm())
+	require.Equal(t, types.PendingTxState, o.State)
+	require.Equal(t, 1, n)
+	require.Equal(t, uint64(1), s.Panics())
+	require.Equal(t, []any{"boom"}, recovered)
+}
+
+func Test_Bus_async(t *testing.T) {
+	b := NewBus()
+
+	var mu sync.Mutex
+	var got []types.TxState
+	_, err := b.Subscribe(Filter{}, func(t Transition) {
+		mu.Lock()
+		defer mu.Unlock()
+		// an asynchronous subscriber never sees the entity, which may have changed since
+		if t.Entity != nil {
+			panic("entity is given to an asynchronous subscriber")
+		}
+		got = append(got, t.To)
+	}, Async(16, Block))
+	require.Nil(t, err)
+
+	o := newBusOrder(t, b, "o-1")
+	require.Nil(t, o.PendingSm())
+	o.ResetTicked()
+	require.Nil(t, o.ApproveSm())
+
+	// Close waits for the buffered transitions
+	b.Close()
+	require.Equal(t, []types.TxState{types.PendingTxState, types.ActiveTxState}, got)
+
+	_, err = b.Subscribe(Filter{}, func(t Transition) {})
+	require.NotNil(t, err)
+	o.ResetTicked()
+	require.Nil(t, o.ModifyPendingSm())
+}
+
+func Test_Bus_backpressure(t *testing.T) {
+	for _, tc := range []struct {
+		policy   BackpressurePolicy
+		expected []types.TxEvent
+	}{
+		{DropNewest, []types.TxEvent{"e1", "e2"}},
+		{DropOldest, []types.TxEvent{"e3", "e4"}},
+	} {
+		b := NewBus()
+		release := make(chan struct{})
+		started := make(chan struct{})
+		var got []types.TxEvent
+		s, err := b.Subscribe(Filter{}, func(t Transition) {
+			if t.Event == "e0" {
+				close(started)
+				<-release
+				return
+			}
+			got = append(got, t.Event)
+		}, Async(2, tc.policy))
+		require.Nil(t, err)
+
+		// e0 blocks the handler, so the buffer of 2 overflows
+		b.Publish(Transition{TransitionEvent: TransitionEvent{Event: "e0"}})
+		<-started
+		for _, ev := range []types.TxEvent{"e1", "e2", "e3", "e4"} {
+			b.Publish(Transition{TransitionEvent: TransitionEvent{Event: ev}})
+		}
+		close(release)
+		s.Unsubscribe()
+
+		require.Equal(t, tc.expected, got)
+		require.Equal(t, uint64(2), s.Dropped())
+	}
+}
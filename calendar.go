@@ -0,0 +1,300 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Calendar counts working time, e.g. for SLA rules. See WithCalendar.
+type Calendar interface {
+	// Elapsed returns the working time between from and to.
+	Elapsed(from, to time.Time) time.Duration
+	// Add returns the time d of working time after from.
+	Add(from time.Time, d time.Duration) time.Time
+}
+
+type alwaysOpen struct{}
+
+func (alwaysOpen) Elapsed(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+func (alwaysOpen) Add(from time.Time, d time.Duration) time.Time {
+	return from.Add(d)
+}
+
+// AlwaysOpen is a calendar where all time is working time.
+var AlwaysOpen Calendar = alwaysOpen{}
+
+// WorkingHours is a range of a day, as offsets from midnight in the time zone of the calendar.
+type WorkingHours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// BusinessCalendar is a calendar of working hours per weekday, except holidays, in a time zone.
+type BusinessCalendar struct {
+	loc      *time.Location
+	hours    [7][]WorkingHours
+	holidays map[string]bool
+}
+
+const dateLayout = "2006-01-02"
+
+// NewBusinessCalendar returns a calendar in loc. Weekdays missing from hours are not worked,
+// and neither are the dates of holidays.
+func NewBusinessCalendar(loc *time.Location, hours map[time.Weekday][]WorkingHours, holidays ...time.Time) (*BusinessCalendar, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	c := &BusinessCalendar{loc: loc, holidays: make(map[string]bool)}
+
+	worked := false
+	for day, v := range hours {
+		if day < time.Sunday || day > time.Saturday {
+			return nil, fmt.Errorf("invalid weekday: %d", day)
+		}
+		v = append([]WorkingHours(nil), v...)
+		sort.Slice(v, func(i, j int) bool { return v[i].Start < v[j].Start })
+		for i, h := range v {
+			if h.Start < 0 || h.End > 24*time.Hour || h.Start >= h.End {
+				return nil, fmt.Errorf("invalid working hours on %s: %s-%s", day, formatClock(h.Start), formatClock(h.End))
+			}
+			if i > 0 && h.Start < v[i-1].End {
+				return nil, fmt.Errorf("working hours overlap on %s", day)
+			}
+		}
+		c.hours[day] = v
+		worked = worked || len(v) > 0
+	}
+	if !worked {
+		return nil, errors.New("calendar has no working hours")
+	}
+
+	for _, d := range holidays {
+		c.holidays[d.Format(dateLayout)] = true
+	}
+	return c, nil
+}
+
+func (c *BusinessCalendar) Location() *time.Location {
+	return c.loc
+}
+
+// IsHoliday reports whether the date of t in the time zone of c is a holiday.
+func (c *BusinessCalendar) IsHoliday(t time.Time) bool {
+	return c.holidays[t.In(c.loc).Format(dateLayout)]
+}
+
+// intervals returns the working time of the day starting at midnight day.
+func (c *BusinessCalendar) intervals(day time.Time) [][2]time.Time {
+	if c.holidays[day.Format(dateLayout)] {
+		return nil
+	}
+	y, m, d := day.Date()
+	var res [][2]time.Time
+	for _, h := range c.hours[day.Weekday()] {
+		res = append(res, [2]time.Time{
+			time.Date(y, m, d, 0, 0, 0, int(h.Start), c.loc),
+			time.Date(y, m, d, 0, 0, 0, int(h.End), c.loc),
+		})
+	}
+	return res
+}
+
+func (c *BusinessCalendar) midnight(t time.Time) time.Time {
+	y, m, d := t.In(c.loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, c.loc)
+}
+
+func nextDay(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+}
+
+func (c *BusinessCalendar) Elapsed(from, to time.Time) time.Duration {
+	var res time.Duration
+	for day := c.midnight(from); day.Before(to); day = nextDay(day) {
+		for _, v := range c.intervals(day) {
+			start, end := v[0], v[1]
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if end.After(start) {
+				res += end.Sub(start)
+			}
+		}
+	}
+	return res
+}
+
+func (c *BusinessCalendar) Add(from time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return from
+	}
+	for day := c.midnight(from); ; day = nextDay(day) {
+		for _, v := range c.intervals(day) {
+			start, end := v[0], v[1]
+			if start.Before(from) {
+				start = from
+			}
+			if !end.After(start) {
+				continue
+			}
+			if avail := end.Sub(start); d <= avail {
+				return start.Add(d)
+			} else {
+				d -= avail
+			}
+		}
+	}
+}
+
+// A calendar file is YAML.
+//
+//	timezone: Asia/Seoul          # optional, UTC by default
+//	hours:                        # required, days not listed are not worked
+//	  mon-fri: 09:00-12:00, 13:00-18:00
+//	  sat: 10:00-13:00
+//	holidays:                     # optional
+//	  - 2024-03-01
+//
+// Days are sun, mon, tue, wed, thu, fri and sat, alone or as a range such as mon-fri.
+
+type calendarFile struct {
+	Timezone string            `yaml:"timezone"`
+	Hours    map[string]string `yaml:"hours"`
+	Holidays []string          `yaml:"holidays"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// LoadCalendarFile reads the calendar file at path.
+func LoadCalendarFile(path string) (*BusinessCalendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := LoadCalendar(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// LoadCalendar reads a calendar file from r.
+func LoadCalendar(r io.Reader) (*BusinessCalendar, error) {
+	var f calendarFile
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if f.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(f.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	hours := make(map[time.Weekday][]WorkingHours)
+	for days, ranges := range f.Hours {
+		from, to, err := parseWeekdays(days)
+		if err != nil {
+			return nil, err
+		}
+		var v []WorkingHours
+		for _, s := range strings.Split(ranges, ",") {
+			h, err := parseWorkingHours(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("hours of %s: %w", days, err)
+			}
+			v = append(v, h)
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			if _, ok := hours[d]; ok {
+				return nil, fmt.Errorf("hours of %s are set twice", d)
+			}
+			hours[d] = v
+			if d == to {
+				break
+			}
+		}
+	}
+
+	var holidays []time.Time
+	for _, s := range f.Holidays {
+		d, err := time.ParseInLocation(dateLayout, s, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday: %q", s)
+		}
+		holidays = append(holidays, d)
+	}
+	return NewBusinessCalendar(loc, hours, holidays...)
+}
+
+func parseWeekdays(s string) (time.Weekday, time.Weekday, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	from, ok := weekdays[strings.ToLower(strings.TrimSpace(first))]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid weekday: %q", first)
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	to, ok := weekdays[strings.ToLower(strings.TrimSpace(last))]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid weekday: %q", last)
+	}
+	return from, to, nil
+}
+
+// parseWorkingHours parses a range such as 09:00-18:00.
+func parseWorkingHours(s string) (WorkingHours, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return WorkingHours{}, fmt.Errorf("invalid working hours: %q", s)
+	}
+	var h WorkingHours
+	var err error
+	if h.Start, err = parseClock(start); err != nil {
+		return WorkingHours{}, err
+	}
+	if h.End, err = parseClock(end); err != nil {
+		return WorkingHours{}, err
+	}
+	return h, nil
+}
+
+// parseClock parses a time of day such as 09:30, or 24:00 for the end of the day.
+func parseClock(s string) (time.Duration, error) {
+	var hh, mm int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &hh, &mm); err != nil || hh < 0 || mm < 0 || mm > 59 || hh*60+mm > 24*60 {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+	return time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute, nil
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
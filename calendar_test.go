@@ -0,0 +1,89 @@
+package state
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_LoadCalendarFile(t *testing.T) {
+	c, err := LoadCalendarFile("testdata/calendar.yaml")
+	require.Nil(t, err)
+	seoul := c.Location()
+	require.Equal(t, "Asia/Seoul", seoul.String())
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 3, day, hour, min, 0, 0, seoul)
+	}
+
+	// 2024-03-01 is a Friday and a holiday, 2024-03-04 a Monday
+	require.True(t, c.IsHoliday(at(1, 10, 0)))
+	require.Equal(t, time.Duration(0), c.Elapsed(at(1, 9, 0), at(4, 9, 0)))
+	require.Equal(t, 3*time.Hour, c.Elapsed(at(1, 9, 0), at(4, 13, 0)))
+	require.Equal(t, 3*time.Hour+30*time.Minute, c.Elapsed(at(4, 8, 0), at(4, 13, 30)))
+	require.Equal(t, 8*time.Hour, c.Elapsed(at(4, 0, 0), at(5, 0, 0)))
+	require.Equal(t, time.Duration(0), c.Elapsed(at(5, 0, 0), at(4, 0, 0)))
+
+	// the same instant in another time zone
+	require.Equal(t, 3*time.Hour, c.Elapsed(at(4, 9, 0).UTC(), at(4, 12, 0).In(time.FixedZone("X", -5*60*60))))
+
+	require.Equal(t, at(4, 14, 0), c.Add(at(1, 17, 0), 4*time.Hour))
+	require.Equal(t, at(4, 12, 0), c.Add(at(1, 17, 0), 3*time.Hour))
+	require.Equal(t, at(5, 10, 0), c.Add(at(4, 17, 0), 2*time.Hour))
+	// 48 working hours are six working days
+	require.Equal(t, at(11, 18, 0), c.Add(at(4, 9, 0), 48*time.Hour))
+}
+
+func Test_LoadCalendar_errors(t *testing.T) {
+	for _, s := range []string{
+		"hours: {}",
+		"hours: {mon: 09:00}",
+		"hours: {mon: 18:00-09:00}",
+		"hours: {mon: 09:00-25:00}",
+		"hours: {mon: 09:00-12:00, mon-fri: 09:00-18:00}",
+		"hours: {mon: 09:00-12:00, 11:00-13:00}",
+		"hours: {funday: 09:00-18:00}",
+		"hours: {mon: 09:00-18:00}\nholidays: [tomorrow]",
+		"hours: {mon: 09:00-18:00}\ntimezone: Nowhere/City",
+		"hours: {mon: 09:00-18:00}\nweekends: [sat]",
+	} {
+		_, err := LoadCalendar(strings.NewReader(s))
+		require.NotNil(t, err, s)
+	}
+
+	// a range may wrap around the week
+	c, err := LoadCalendar(strings.NewReader("hours: {sat-sun: 00:00-24:00}"))
+	require.Nil(t, err)
+	sat := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, 48*time.Hour, c.Elapsed(sat, sat.Add(7*24*time.Hour)))
+}
+
+func Test_SLA_WithCalendar(t *testing.T) {
+	cal, err := LoadCalendarFile("testdata/calendar.yaml")
+	require.Nil(t, err)
+	friday := time.Date(2024, 3, 8, 17, 0, 0, 0, cal.Location())
+	c := NewManualClock(friday)
+	w, err := DefaultWorkflow().With(WithClock(c), WithCalendar(cal),
+		WithSLA(SLARule{State: types.PendingTxState, Warning: 8 * time.Hour, Breach: 16 * time.Hour}))
+	require.Nil(t, err)
+
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.PendingSm())
+
+	sunday := friday.Add(48 * time.Hour)
+	require.Equal(t, 48*time.Hour, e.TimeInCurrentStateSm(sunday))
+	require.Equal(t, SLAOK, e.SLAStatusSm(sunday))
+	require.Equal(t, time.Hour, e.SLAReportSm(sunday).Elapsed)
+
+	deadline, ok := e.SLADeadlineSm()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, 3, 12, 17, 0, 0, 0, cal.Location()), deadline)
+	require.Equal(t, SLAWarning, e.SLAStatusSm(deadline.Add(-time.Hour)))
+	require.Equal(t, SLABreached, e.SLAStatusSm(deadline))
+
+	_, ok = (&TxStateMachineClock{}).SLADeadlineSm()
+	require.False(t, ok)
+}
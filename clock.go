@@ -0,0 +1,119 @@
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time set to CreatedAt and UpdatedAt by Tick.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the clock of the machine.
+var SystemClock Clock = ClockFunc(time.Now)
+
+type clockHolder struct {
+	Clock
+}
+
+var defaultClock atomic.Value
+
+func init() {
+	defaultClock.Store(clockHolder{SystemClock})
+}
+
+// DefaultClock returns the clock of TxClock, and of a Stateful unless its workflow uses WithClock.
+func DefaultClock() Clock {
+	return defaultClock.Load().(clockHolder).Clock
+}
+
+// SetDefaultClock replaces the clock returned by DefaultClock for the whole process.
+// A nil c restores SystemClock.
+func SetDefaultClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	defaultClock.Store(clockHolder{c})
+}
+
+type clockOptions struct {
+	utc       bool
+	precision time.Duration
+}
+
+// ClockOption configures a clock made by NewClock.
+type ClockOption func(o *clockOptions)
+
+// ClockUTC converts times to UTC.
+func ClockUTC() ClockOption {
+	return func(o *clockOptions) {
+		o.utc = true
+	}
+}
+
+// ClockPrecision truncates times to a multiple of d, e.g. time.Microsecond for PostgreSQL,
+// so that they compare equal after a round trip through the database.
+// The monotonic clock reading is stripped as well.
+func ClockPrecision(d time.Duration) ClockOption {
+	return func(o *clockOptions) {
+		o.precision = d
+	}
+}
+
+// NewClock returns a clock telling the time of base, normalized by opts.
+func NewClock(base Clock, opts ...ClockOption) Clock {
+	var o clockOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return ClockFunc(func() time.Time {
+		t := base.Now()
+		if o.utc {
+			t = t.UTC()
+		}
+		if o.precision > 0 {
+			t = t.Truncate(o.precision)
+		}
+		return t
+	})
+}
+
+// ManualClock is a clock that only moves when told to. It is meant for tests.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves c to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves c forward by d and returns the new time.
+func (c *ManualClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
@@ -0,0 +1,63 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewClock(t *testing.T) {
+	seoul := time.FixedZone("KST", 9*60*60)
+	base := NewManualClock(time.Date(2024, 3, 1, 9, 30, 0, 123456789, seoul))
+
+	c := NewClock(base, ClockUTC(), ClockPrecision(time.Microsecond))
+	now := c.Now()
+	require.Equal(t, time.UTC, now.Location())
+	require.Equal(t, time.Date(2024, 3, 1, 0, 30, 0, 123456000, time.UTC), now)
+
+	require.Equal(t, seoul, NewClock(base).Now().Location())
+	require.Equal(t, time.Date(2024, 3, 1, 0, 30, 1, 123456000, time.UTC), func() time.Time {
+		base.Advance(time.Second)
+		return c.Now()
+	}())
+}
+
+func Test_TxClock_DefaultClock(t *testing.T) {
+	c := NewManualClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	SetDefaultClock(c)
+	defer SetDefaultClock(nil)
+
+	e := &TxClock{}
+	e.Tick()
+	created := *e.CreatedAt
+	require.Equal(t, c.Now(), created)
+
+	e.ResetTicked()
+	c.Advance(time.Hour)
+	e.Tick()
+	require.Equal(t, created, *e.CreatedAt)
+	require.Equal(t, created.Add(time.Hour), *e.UpdatedAt)
+}
+
+func Test_TxStateMachineClock_WithClock(t *testing.T) {
+	c := NewManualClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	w, err := DefaultWorkflow().With(WithClock(c))
+	require.Nil(t, err)
+	require.Same(t, c, w.Clock())
+
+	process := NewManualClock(time.Time{})
+	SetDefaultClock(process)
+	defer SetDefaultClock(nil)
+	require.Same(t, process, DefaultWorkflow().Clock())
+
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	c.Advance(time.Minute)
+	require.Nil(t, e.ApproveSm())
+
+	require.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *e.CreatedAt)
+	require.Equal(t, time.Date(2024, 3, 1, 0, 1, 0, 0, time.UTC), *e.UpdatedAt)
+}
@@ -0,0 +1,263 @@
+// Command statectl inspects and simulates workflows of github.com/wonksing/state.
+//
+// Usage:
+//
+//	statectl graph [--workflow=<file>] [--format=mermaid|dot|plantuml]
+//	statectl check <workflow-file>
+//	statectl simulate [--workflow=<file>] <event>...
+//	statectl next [--workflow=<file>] <state>
+//
+// Without --workflow, the commands use state.DefaultWorkflow.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `usage: statectl <command> [arguments]
+
+commands:
+  graph [--format=mermaid|dot|plantuml]  render the lifecycle
+  check <workflow-file>                  validate a workflow definition
+  simulate <event>...                    fire events on a new entity and print each step
+  next <state>                           list events permitted in a state
+
+graph, simulate and next read a workflow file with --workflow=<file>
+instead of using the default lifecycle.
+`
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "graph":
+		err = runGraph(args[1:], stdout)
+	case "check":
+		err = runCheck(args[1:], stdout)
+	case "simulate":
+		err = runSimulate(args[1:], stdout)
+	case "next":
+		err = runNext(args[1:], stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = fmt.Errorf("unknown command: %s", args[0])
+	}
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "statectl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("usage")
+
+// newFlagSet returns a flag set with the --workflow flag shared by commands.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	path := fs.String("workflow", "", "workflow file")
+	return fs, path
+}
+
+// loadWorkflow returns the workflow at path, or the default workflow if path is empty.
+// Guards are registered in code, so every guard of the file is accepted.
+func loadWorkflow(path string) (*state.Workflow, error) {
+	if path == "" {
+		return state.DefaultWorkflow(), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := state.ParseWorkflowDefinition(path, b)
+	if err != nil {
+		return nil, err
+	}
+	var opts []state.WorkflowOption
+	for _, g := range d.Guards() {
+		opts = append(opts, state.WithGuard(g, func(types.TxTransition) error { return nil }))
+	}
+	return d.Build(opts...)
+}
+
+func runGraph(args []string, stdout io.Writer) error {
+	fs, path := newFlagSet("graph")
+	format := fs.String("format", "mermaid", "mermaid, dot or plantuml")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	w, err := loadWorkflow(*path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(*format) {
+	case "mermaid":
+		fmt.Fprint(stdout, w.ExportMermaid())
+	case "dot":
+		fmt.Fprint(stdout, w.ExportDOT())
+	case "plantuml":
+		fmt.Fprint(stdout, w.ExportPlantUML())
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	return nil
+}
+
+func runCheck(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	w, err := loadWorkflow(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: ok (%d states, %d events, %d transitions)\n", args[0], len(w.States()), len(w.Events()), len(w.Transitions()))
+	return nil
+}
+
+var simulatedEvents = map[types.TxEvent]func(e *state.TxStateMachineClock) error{
+	types.PendingTxEvent:         (*state.TxStateMachineClock).PendingSm,
+	types.ModifyPendingTxEvent:   (*state.TxStateMachineClock).ModifyPendingSm,
+	types.RemovePendingTxEvent:   (*state.TxStateMachineClock).RemovePendingSm,
+	types.InactivePendingTxEvent: (*state.TxStateMachineClock).InactivePendingSm,
+	types.ActivePendingTxEvent:   (*state.TxStateMachineClock).ActivePendingSm,
+	types.ApproveTxEvent:         (*state.TxStateMachineClock).ApproveSm,
+	types.CancelTxEvent:          (*state.TxStateMachineClock).CancelSm,
+}
+
+// runSimulate fires events on a new entity the same way the *Sm methods do.
+// Events of a workflow file are fired with FireSm.
+// A rejected event leaves the entity as it was and the simulation goes on.
+func runSimulate(args []string, stdout io.Writer) error {
+	fs, path := newFlagSet("simulate")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	args = fs.Args()
+
+	w, err := loadWorkflow(*path)
+	if err != nil {
+		return err
+	}
+	e := &state.TxStateMachineClock{}
+	if err := e.SetWorkflowSm(w); err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, arg := range args {
+		ev := types.TxEvent(arg)
+		fire, ok := simulatedEvents[ev]
+		if *path != "" {
+			fire = func(e *state.TxStateMachineClock) error { return e.FireSm(ev) }
+			ok = hasEvent(w, ev)
+		}
+
+		// a new entity starts at the initial state unless ev is declared for an entity without a state
+		if _, declared := lookup(w, "", ev); e.State == "" && !declared {
+			if err := e.InitSm(""); err != nil {
+				return err
+			}
+		}
+		from := e.State
+		var err error
+		if !ok {
+			err = fmt.Errorf("unknown event: %s", arg)
+		} else {
+			err = fire(e)
+		}
+		e.ResetTicked()
+
+		if err != nil {
+			failed++
+			fmt.Fprintf(stdout, "%d\t%s\t%s\terror: %v\n", i+1, arg, displayState(from), err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%d\t%s\t%s -> %s\tversion %d\n", i+1, arg, displayState(from), e.State, e.Version)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events failed", failed, len(args))
+	}
+	return nil
+}
+
+func runNext(args []string, stdout io.Writer) error {
+	fs, path := newFlagSet("next")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	w, err := loadWorkflow(*path)
+	if err != nil {
+		return err
+	}
+	s := types.TxState(fs.Arg(0))
+	if !w.HasState(s) {
+		return fmt.Errorf("unknown state: %s", s)
+	}
+	ts := w.Outgoing(s)
+	if len(ts) == 0 {
+		fmt.Fprintf(stdout, "no events are permitted in %s\n", s)
+		return nil
+	}
+	for _, t := range ts {
+		if t.Guard != "" {
+			fmt.Fprintf(stdout, "%s\t-> %s\t[%s]\n", t.Event, t.To, t.Guard)
+		} else {
+			fmt.Fprintf(stdout, "%s\t-> %s\n", t.Event, t.To)
+		}
+	}
+	return nil
+}
+
+func lookup(w *state.Workflow, from types.TxState, ev types.TxEvent) (types.TxTransition, bool) {
+	for _, t := range w.Outgoing(from) {
+		if t.Event == ev {
+			return t, true
+		}
+	}
+	return types.TxTransition{}, false
+}
+
+func hasEvent(w *state.Workflow, ev types.TxEvent) bool {
+	for _, v := range w.Events() {
+		if v == ev {
+			return true
+		}
+	}
+	return false
+}
+
+func displayState(s types.TxState) string {
+	if s == "" {
+		return "(none)"
+	}
+	return string(s)
+}
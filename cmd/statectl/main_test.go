@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runForTest(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func Test_graph(t *testing.T) {
+	code, out, _ := runForTest("graph", "--format=mermaid")
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(out, "stateDiagram-v2"))
+
+	code, out, _ = runForTest("graph", "--format=dot")
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(out, "digraph"))
+
+	code, out, _ = runForTest("graph", "--workflow=../../testdata/ticket.yaml", "--format=mermaid")
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "review --> closed: close [approved]")
+
+	code, out, _ = runForTest("graph", "--format=plantuml")
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(out, "@startuml"))
+
+	code, _, errOut := runForTest("graph", "--format=svg")
+	require.Equal(t, 1, code)
+	require.Contains(t, errOut, "unknown format")
+}
+
+func Test_check(t *testing.T) {
+	code, out, _ := runForTest("check", "testdata/ticket.json")
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "ok (3 states, 2 events, 2 transitions)")
+
+	code, out, _ = runForTest("check", "../../testdata/ticket.yaml")
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "ok (3 states, 2 events, 2 transitions)")
+
+	code, _, errOut := runForTest("check", "testdata/broken.json")
+	require.Equal(t, 1, code)
+	require.Contains(t, errOut, "testdata/broken.json:7: state is not declared: closed")
+
+	code, _, _ = runForTest("check")
+	require.Equal(t, 2, code)
+}
+
+func Test_simulate(t *testing.T) {
+	code, out, _ := runForTest("simulate", "pending", "approve", "modify_pending", "cancel")
+	require.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "1\tpending\t(none) -> pending\tversion 1", lines[0])
+	require.Equal(t, "2\tapprove\tpending -> active\tversion 2", lines[1])
+	require.Equal(t, "3\tmodify_pending\tactive -> modify_pending\tversion 3", lines[2])
+	require.Equal(t, "4\tcancel\tmodify_pending -> active\tversion 4", lines[3])
+
+	code, out, errOut := runForTest("simulate", "pending", "approve", "cancel")
+	require.Equal(t, 1, code)
+	require.Contains(t, out, "3\tcancel\tactive\terror: cancel is not permitted in active state")
+	require.Contains(t, errOut, "1 of 3 events failed")
+}
+
+func Test_simulate_workflow(t *testing.T) {
+	code, out, _ := runForTest("simulate", "--workflow=../../testdata/ticket.yaml", "submit", "close")
+	require.Equal(t, 0, code)
+	require.Equal(t, "1\tsubmit\topen -> review\tversion 1\n2\tclose\treview -> closed\tversion 2\n", out)
+
+	code, out, _ = runForTest("simulate", "--workflow=../../testdata/ticket.yaml", "close", "approve")
+	require.Equal(t, 1, code)
+	require.Contains(t, out, "1\tclose\topen\terror: close is not permitted in open state")
+	require.Contains(t, out, "2\tapprove\topen\terror: unknown event: approve")
+}
+
+func Test_next(t *testing.T) {
+	code, out, _ := runForTest("next", "pending")
+	require.Equal(t, 0, code)
+	require.Equal(t, "approve\t-> active\ncancel\t-> canceled\nmodify_pending\t-> pending\n", out)
+
+	code, out, _ = runForTest("next", "canceled")
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "no events are permitted")
+
+	code, _, _ = runForTest("next", "archived")
+	require.Equal(t, 1, code)
+
+	code, out, _ = runForTest("next", "--workflow=../../testdata/ticket.yaml", "review")
+	require.Equal(t, 0, code)
+	require.Equal(t, "close\t-> closed\t[approved]\n", out)
+}
+
+func Test_usage(t *testing.T) {
+	code, _, errOut := runForTest()
+	require.Equal(t, 2, code)
+	require.Contains(t, errOut, "usage")
+
+	code, _, _ = runForTest("unknown")
+	require.Equal(t, 1, code)
+}
@@ -0,0 +1,49 @@
+package state
+
+import (
+	"reflect"
+)
+
+// Identifier is implemented by hosts of TxStateMachine or TxStateMachineClock
+// that identify themselves in records of their transitions.
+type Identifier interface {
+	EntityID() string
+}
+
+// EntityTyper is implemented by hosts that name their type in records of their transitions.
+// Without it, the name of the Go type is used.
+type EntityTyper interface {
+	EntityType() string
+}
+
+// EntityTypeOf returns the entity type of host.
+func EntityTypeOf(host any) string {
+	if v, ok := host.(EntityTyper); ok {
+		return v.EntityType()
+	}
+	t := reflect.TypeOf(host)
+	if t == nil {
+		return ""
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// EntityIDOf returns the identifier of host, or an empty string if host is not an Identifier.
+func EntityIDOf(host any) string {
+	if v, ok := host.(Identifier); ok {
+		return v.EntityID()
+	}
+	return ""
+}
+
+// entityOf names the entity of sm, a state machine following w, embedded by host if host is not nil.
+// Without a host, the entity is sm and its type is the name of w.
+func (w *Workflow) entityOf(host, sm any) (entity any, entityType, id string) {
+	if host == nil {
+		return sm, w.Name(), ""
+	}
+	return host, EntityTypeOf(host), EntityIDOf(host)
+}
@@ -0,0 +1,227 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// EscalationRule reassigns an entity to To once it has been in State for After,
+// counting the working time of the calendar of the workflow. State must be of the pending category.
+type EscalationRule struct {
+	State types.TxState
+	After time.Duration
+	To    string
+	// Reason is recorded with the reassignment. It defaults to how long the entity has been in State.
+	Reason string
+}
+
+// WithEscalation adds rules to the workflow. Rules of a state apply one after another, in the order of After.
+func WithEscalation(rules ...EscalationRule) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.escalation = append(o.escalation, rules...)
+	}
+}
+
+func validateEscalation(w *internal.TxWorkflow, rules []EscalationRule) error {
+	for _, r := range rules {
+		s, ok := w.State(r.State)
+		if !ok {
+			return fmt.Errorf("state of escalation rule is not declared: %s", r.State)
+		}
+		if s.Category != types.PendingTxCategory {
+			return fmt.Errorf("escalation rule of %s must be on a pending state", r.State)
+		}
+		if r.After <= 0 || r.To == "" {
+			return fmt.Errorf("escalation rule of %s must have a positive delay and an assignee", r.State)
+		}
+	}
+	return nil
+}
+
+// EscalationRules returns the rules of s in the order of After.
+func (w *Workflow) EscalationRules(s types.TxState) []EscalationRule {
+	var res []EscalationRule
+	for _, r := range w.opts.escalation {
+		if r.State == s {
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].After < res[j].After })
+	return res
+}
+
+// Delegation makes To stand in for From from Start until End. A zero End never ends.
+type Delegation struct {
+	From  string
+	To    string
+	Start time.Time
+	End   time.Time
+}
+
+func (d Delegation) activeAt(now time.Time) bool {
+	return !now.Before(d.Start) && (d.End.IsZero() || now.Before(d.End))
+}
+
+// Delegations is a list of delegations. The first one active for an assignee wins.
+type Delegations []Delegation
+
+// Resolve returns who stands in for assignee at now, following delegations of delegates.
+// A cycle stops at the last assignee before it repeats.
+func (ds Delegations) Resolve(assignee string, now time.Time) string {
+	seen := map[string]bool{assignee: true}
+	for {
+		next, ok := ds.delegate(assignee, now)
+		if !ok || seen[next] {
+			return assignee
+		}
+		seen[next] = true
+		assignee = next
+	}
+}
+
+func (ds Delegations) delegate(assignee string, now time.Time) (string, bool) {
+	for _, d := range ds {
+		if d.From == assignee && d.activeAt(now) {
+			return d.To, true
+		}
+	}
+	return "", false
+}
+
+// Escalation is an entity reassigned by Escalate.
+type Escalation struct {
+	Entity     any
+	EntityType string
+	EntityID   string
+	State      types.TxState
+	From       string
+	To         string
+	Reason     string
+	At         time.Time
+}
+
+// Assignment is who is to act on an entity in a pending state, see AssignSm and Escalate.
+// It is optional: embed it in the host of a state machine and give the host to SetHostSm.
+// It is cleared by any change to a state that is not pending.
+type Assignment struct {
+	Assignee     string     `gorm:"<-;size:64" json:"assignee,omitempty"`
+	AssignedAt   *time.Time `gorm:"<-" json:"assigned_at,omitempty"`
+	AssignReason string     `gorm:"<-" json:"assign_reason,omitempty"`
+}
+
+func (a *Assignment) assignment() *Assignment {
+	return a
+}
+
+// hostAssignment returns the Assignment embedded by the host of e, or nil.
+func (e *core[C, PC]) hostAssignment() *Assignment {
+	if h, ok := e.host.(interface{ assignment() *Assignment }); ok {
+		return h.assignment()
+	}
+	return nil
+}
+
+// AssignSm records assignee as the one to act on e, which must be in a pending state.
+// It ticks like a transition and records a change of kind AssignedEventKind with reason.
+// It fails if the host of e does not embed Assignment.
+func (e *core[C, PC]) AssignSm(assignee, reason string) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.assign(assignee, reason, e.WorkflowSm().Clock().Now)
+}
+
+func (e *core[C, PC]) assign(assignee, reason string, now func() time.Time) error {
+	o := TransitionOutcome{Kind: AssignedEventKind, Reason: reason, Assignee: assignee}
+	return e.changeAt(o, now, func(m *internal.TxStateMachine) error {
+		if e.hostAssignment() == nil {
+			return errors.New("host does not embed Assignment, see SetHostSm")
+		}
+		if !m.IsPendingKind() {
+			return fmt.Errorf("%q is not a pending state", m.State)
+		}
+		return nil
+	})
+}
+
+// isPending reports whether s belongs to types.PendingTxCategory.
+func (w *Workflow) isPending(s types.TxState) bool {
+	v, ok := w.table.State(s)
+	return ok && v.Category == types.PendingTxCategory
+}
+
+// EscalateSm reassigns e at now if an escalation rule of its state is due since it was assigned,
+// then to whoever stands in for the assignee according to ds.
+// It returns false if e is not in a pending state or keeps its assignee, and an error if e fails to be reassigned,
+// e.g. by an Authorizer or because its host does not embed Assignment.
+func (e *core[C, PC]) EscalateSm(now time.Time, ds Delegations) (Escalation, bool, error) {
+	if e == nil || !e.IsPendingKindSm() {
+		return Escalation{}, false, nil
+	}
+	a := e.hostAssignment()
+	if a == nil {
+		return Escalation{}, false, errors.New("host does not embed Assignment, see SetHostSm")
+	}
+	res := Escalation{State: *e.state, From: a.Assignee, To: a.Assignee, At: now}
+
+	w := e.WorkflowSm()
+	if at, ok := e.enteredAt(); ok {
+		elapsed := w.Calendar().Elapsed(at, now)
+		for _, r := range w.EscalationRules(*e.state) {
+			due := w.Calendar().Add(at, r.After)
+			if elapsed < r.After || (a.AssignedAt != nil && !a.AssignedAt.Before(due)) {
+				continue
+			}
+			res.To, res.Reason = r.To, r.Reason
+			if res.Reason == "" {
+				res.Reason = fmt.Sprintf("%s in %s", r.After, *e.state)
+			}
+		}
+	}
+
+	if to := ds.Resolve(res.To, now); to != res.To {
+		reason := fmt.Sprintf("%s is delegated to %s", res.To, to)
+		if res.Reason != "" {
+			reason = res.Reason + ", " + reason
+		}
+		res.To, res.Reason = to, reason
+	}
+	if res.To == a.Assignee {
+		return Escalation{}, false, nil
+	}
+	if err := e.assign(res.To, res.Reason, func() time.Time { return now }); err != nil {
+		return Escalation{}, false, err
+	}
+	return res, true, nil
+}
+
+// Escalatable is an entity whose assignee can be escalated, e.g. a host of TxStateMachineClock embedding Assignment.
+type Escalatable interface {
+	EscalateSm(now time.Time, ds Delegations) (Escalation, bool, error)
+}
+
+// Escalate reassigns the entities due for escalation or delegation at now, see EscalateSm,
+// and returns the reassignments in the order of entities. Passing the same entities and now again changes nothing.
+// Entities failing to be reassigned do not stop the others; their errors are joined, naming the entity.
+func Escalate[T Escalatable](entities []T, now time.Time, ds Delegations) ([]Escalation, error) {
+	var res []Escalation
+	var errs []error
+	for _, v := range entities {
+		x, ok, err := v.EscalateSm(now, ds)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", EntityTypeOf(v), EntityIDOf(v), err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		x.Entity, x.EntityType, x.EntityID = v, EntityTypeOf(v), EntityIDOf(v)
+		res = append(res, x)
+	}
+	return res, errors.Join(errs...)
+}
@@ -0,0 +1,244 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func newEscalationWorkflow(t *testing.T, c Clock) *Workflow {
+	w, err := DefaultWorkflow().With(WithClock(c), WithEscalation(
+		EscalationRule{State: types.PendingTxState, After: 48 * time.Hour, To: "manager", Reason: "stalled for two days"},
+		EscalationRule{State: types.PendingTxState, After: 24 * time.Hour, To: "group-x"},
+	))
+	require.Nil(t, err)
+	return w
+}
+
+func Test_Escalate(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	c := NewManualClock(t0)
+	w := newEscalationWorkflow(t, c)
+
+	var orders []*trackedOrder
+	for _, id := range []string{"o-1", "o-2", "o-3"} {
+		o := newTrackedOrder(t, w)
+		o.ID = id
+		require.Nil(t, o.PendingSm())
+		require.Nil(t, o.AssignSm("alice", "requested"))
+		orders = append(orders, o)
+	}
+	require.Nil(t, orders[1].ApproveSm())
+	c.Advance(12 * time.Hour)
+	require.Nil(t, orders[2].AssignSm("carol", "picked up"))
+
+	got, err := Escalate(orders, t0.Add(23*time.Hour), nil)
+	require.Nil(t, err)
+	require.Empty(t, got)
+
+	now := t0.Add(25 * time.Hour)
+	got, err = Escalate(orders, now, nil)
+	require.Nil(t, err)
+	require.Equal(t, []Escalation{
+		{Entity: orders[0], EntityType: "trackedOrder", EntityID: "o-1", State: types.PendingTxState, From: "alice", To: "group-x", Reason: "24h0m0s in pending", At: now},
+		{Entity: orders[2], EntityType: "trackedOrder", EntityID: "o-3", State: types.PendingTxState, From: "carol", To: "group-x", Reason: "24h0m0s in pending", At: now},
+	}, got)
+	require.Equal(t, "group-x", orders[0].Assignee)
+	require.Equal(t, now, *orders[0].AssignedAt)
+	require.Equal(t, "24h0m0s in pending", orders[0].AssignReason)
+	// the approval of orders[1] cleared its assignee
+	require.Equal(t, "", orders[1].Assignee)
+	require.Nil(t, orders[1].AssignedAt)
+
+	// the same pass again changes nothing
+	got, err = Escalate(orders, now, nil)
+	require.Nil(t, err)
+	require.Empty(t, got)
+
+	now = t0.Add(49 * time.Hour)
+	got, err = Escalate(orders, now, nil)
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "manager", got[0].To)
+	require.Equal(t, "stalled for two days", got[0].Reason)
+
+	// a manual assignment after a rule is due is not overridden by the rule
+	c.Set(t0.Add(49*time.Hour + 30*time.Minute))
+	require.Nil(t, orders[2].AssignSm("dave", "back from leave"))
+	got, err = Escalate(orders, t0.Add(50*time.Hour), nil)
+	require.Nil(t, err)
+	require.Empty(t, got)
+}
+
+func Test_Escalate_skips_rules_already_due(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	w := newEscalationWorkflow(t, NewManualClock(t0))
+	o := newTrackedOrder(t, w)
+	require.Nil(t, o.PendingSm())
+
+	x, ok, err := o.EscalateSm(t0.Add(72*time.Hour), nil)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "", x.From)
+	require.Equal(t, "manager", x.To)
+}
+
+func Test_Escalate_delegations(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	w := newEscalationWorkflow(t, NewManualClock(t0))
+	ds := Delegations{
+		{From: "alice", To: "bob", Start: t0, End: t0.Add(72 * time.Hour)},
+		{From: "group-x", To: "erin", Start: t0.Add(24 * time.Hour)},
+		{From: "bob", To: "carol", Start: t0.Add(48 * time.Hour)},
+	}
+
+	o := newTrackedOrder(t, w)
+	require.Nil(t, o.PendingSm())
+	require.Nil(t, o.AssignSm("alice", "requested"))
+
+	x, ok, err := o.EscalateSm(t0.Add(time.Hour), ds)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "bob", x.To)
+	require.Equal(t, "alice is delegated to bob", x.Reason)
+
+	x, ok, err = o.EscalateSm(t0.Add(30*time.Hour), ds)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "erin", x.To)
+	require.Equal(t, "24h0m0s in pending, group-x is delegated to erin", x.Reason)
+
+	// delegations are followed and stop at a cycle
+	require.Equal(t, "carol", ds.Resolve("alice", t0.Add(50*time.Hour)))
+	require.Equal(t, "alice", ds.Resolve("alice", t0.Add(80*time.Hour)))
+	cycle := Delegations{{From: "a", To: "b"}, {From: "b", To: "a"}}
+	require.Equal(t, "b", cycle.Resolve("a", t0))
+}
+
+func Test_AssignSm_pending_only(t *testing.T) {
+	e := newTrackedOrder(t, DefaultWorkflow())
+	e.State = types.ActiveTxState
+	require.NotNil(t, e.AssignSm("alice", ""))
+	require.Nil(t, e.ModifyPendingSm())
+	require.Nil(t, e.AssignSm("alice", ""))
+	require.Equal(t, "alice", e.Assignee)
+
+	_, ok, err := e.EscalateSm(time.Now(), nil)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func Test_AssignSm_requires_Assignment(t *testing.T) {
+	o := &busOrder{ID: "o-1", TxStateMachineClock: TxStateMachineClock{State: types.PendingTxState}}
+	o.SetHostSm(o)
+	require.NotNil(t, o.AssignSm("alice", ""))
+	require.EqualValues(t, 0, o.Version)
+
+	_, ok, err := o.EscalateSm(time.Now(), nil)
+	require.NotNil(t, err)
+	require.False(t, ok)
+	got, err := Escalate([]*busOrder{o}, time.Now(), nil)
+	require.ErrorContains(t, err, "busOrder o-1")
+	require.Empty(t, got)
+}
+
+func Test_Escalate_returns_errors(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	w, err := newEscalationWorkflow(t, NewManualClock(t0)).With(WithAuthorizer(AuthorizerFunc(func(r AuthRequest) error {
+		if r.Kind == AssignedEventKind && r.Actor == "mallory" {
+			return ErrForbidden
+		}
+		return nil
+	})))
+	require.Nil(t, err)
+	var orders []*trackedOrder
+	for _, id := range []string{"o-1", "o-2"} {
+		o := newTrackedOrder(t, w)
+		o.ID = id
+		require.Nil(t, o.PendingSm())
+		orders = append(orders, o)
+	}
+	orders[0].SetActorSm("mallory")
+
+	// the escalation of o-1 is denied, which does not stop o-2
+	got, err := Escalate(orders, t0.Add(30*time.Hour), nil)
+	require.ErrorIs(t, err, ErrForbidden)
+	require.ErrorContains(t, err, "trackedOrder o-1")
+	require.Empty(t, orders[0].Assignee)
+	require.Len(t, got, 1)
+	require.Equal(t, "o-2", got[0].EntityID)
+}
+
+func Test_WithEscalation_invalid(t *testing.T) {
+	for _, r := range []EscalationRule{
+		{State: "unknown", After: time.Hour, To: "x"},
+		{State: types.ActiveTxState, After: time.Hour, To: "x"},
+		{State: types.PendingTxState, To: "x"},
+		{State: types.PendingTxState, After: time.Hour},
+	} {
+		_, err := DefaultWorkflow().With(WithEscalation(r))
+		require.NotNil(t, err, r)
+	}
+}
+
+func Test_AssignSm_is_a_change(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	b := NewBus()
+	var published []Transition
+	_, err := b.Subscribe(Filter{Kind: AssignedEventKind}, func(t Transition) { published = append(published, t) })
+	require.Nil(t, err)
+	// an assignment keeps the state, so it does not enter it again
+	var entered []Transition
+	_, err = b.Subscribe(Filter{To: types.PendingTxState}, func(t Transition) { entered = append(entered, t) })
+	require.Nil(t, err)
+	var observed []TransitionOutcome
+	w, err := newEventSourcedWorkflow(t).With(WithClock(NewManualClock(t0)), WithBus(b),
+		WithObserver(TransitionObserverFunc(func(o TransitionOutcome) { observed = append(observed, o) })),
+		WithAuthorizer(AuthorizerFunc(func(r AuthRequest) error {
+			if r.Kind == AssignedEventKind && r.Assignee == "mallory" {
+				return ErrForbidden
+			}
+			return nil
+		})))
+	require.Nil(t, err)
+
+	o := newTrackedOrder(t, w)
+	require.Nil(t, o.PendingSm())
+	require.Nil(t, o.AssignSm("alice", "requested"))
+	require.EqualValues(t, types.PendingTxState, o.State)
+
+	err = o.AssignSm("mallory", "")
+	require.ErrorIs(t, err, ErrForbidden)
+	require.Equal(t, "alice", o.Assignee)
+
+	require.Len(t, published, 1)
+	require.Len(t, entered, 1)
+	require.Equal(t, "alice", published[0].Assignee)
+	require.Equal(t, "requested", published[0].Reason)
+	require.Len(t, observed, 3)
+	require.Equal(t, AssignedEventKind, observed[1].Kind)
+	require.Equal(t, "alice", observed[1].Assignee)
+	require.Equal(t, ForbiddenError, observed[2].ErrorKind)
+
+	s, err := Replay(w, nil, o.UncommittedEventsSm())
+	require.Nil(t, err)
+	require.Equal(t, "alice", s.Assignee)
+	require.Equal(t, t0, *s.AssignedAt)
+	require.Equal(t, "requested", s.AssignReason)
+	require.Equal(t, s, o.SnapshotSm())
+	r := newTrackedOrder(t, w)
+	require.Nil(t, r.ReplaySm(nil, o.UncommittedEventsSm()))
+	require.Equal(t, "alice", r.Assignee)
+
+	// leaving the pending state clears the assignment
+	require.Nil(t, o.ApproveSm())
+	require.Equal(t, "", o.Assignee)
+	require.Nil(t, o.AssignedAt)
+	require.Equal(t, "", o.AssignReason)
+	s, err = Replay(w, nil, o.UncommittedEventsSm())
+	require.Nil(t, err)
+	require.Equal(t, "", s.Assignee)
+	require.Nil(t, s.AssignedAt)
+}
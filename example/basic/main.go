@@ -12,6 +12,12 @@ func main() {
 	p := &Person{
 		Name: "John",
 	}
+	// forcing states without a reason must be allowed by the workflow
+	w, err := state.DefaultWorkflow().With(state.WithUnrestrictedForce())
+	if err != nil {
+		panic(err)
+	}
+	p.SetWorkflowSm(w)
 	p.PendingSm()
 	fmt.Println(p.Name, p.State)
 	p.ApproveSm()
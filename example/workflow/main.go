@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+)
+
+// The default file is relative to the root of the repository.
+var workflowFile = flag.String("workflow", "testdata/ticket.yaml", "workflow definition file")
+
+func main() {
+	flag.Parse()
+	w, err := state.LoadWorkflowFile(*workflowFile,
+		state.WithGuard("approved", func(t types.TxTransition) error { return nil }))
+	if err != nil {
+		panic(err)
+	}
+
+	t := &Ticket{Title: "printer is broken"}
+	if err := t.SetWorkflowSm(w); err != nil {
+		panic(err)
+	}
+	if err := t.InitSm(""); err != nil {
+		panic(err)
+	}
+	fmt.Println(t.Title, t.State)
+	for _, ev := range []types.TxEvent{"submit", "close"} {
+		if err := t.FireSm(ev); err != nil {
+			panic(err)
+		}
+		fmt.Println(t.Title, t.State)
+	}
+}
+
+type Ticket struct {
+	Title string `json:"title,omitempty"`
+	state.TxStateMachineClock
+}
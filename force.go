@@ -0,0 +1,152 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// ErrForbidden is wrapped by the errors of changes the workflow does not allow to be made.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrReasonRequired is wrapped by the error of OverrideSm without a reason.
+var ErrReasonRequired = errors.New("reason is required")
+
+// Override is a request to force an entity to a state regardless of the transitions of its workflow, see OverrideSm.
+type Override struct {
+	To types.TxState
+	// Reason is why the state is forced. It is required and recorded with the change.
+	Reason string
+	// Token is a capability presented to the ForcePolicy of the workflow.
+	Token string
+}
+
+// ForceRequest is an Override presented to ForcePolicy.Authorize.
+type ForceRequest struct {
+	Override
+	Workflow   string
+	EntityType string
+	EntityID   string
+	Entity     any
+	// Actor is the actor given to SetActorSm.
+	Actor string
+	From  types.TxState
+}
+
+// ForcePolicy restricts OverrideSm on entities following a workflow, see WithForcePolicy.
+type ForcePolicy struct {
+	// Authorize allows a request by returning nil, e.g. after checking its Token or Actor. It is required.
+	Authorize func(r ForceRequest) error
+	// Targets lists the states each state may be forced to. A state missing from Targets may not be forced.
+	// Nil Targets allow any declared state.
+	Targets map[types.TxState][]types.TxState
+}
+
+func (p *ForcePolicy) validate(w *internal.TxWorkflow) error {
+	if p.Authorize == nil {
+		return errors.New("force policy must authorize requests")
+	}
+	for from, targets := range p.Targets {
+		if from != "" && !w.HasState(from) {
+			return fmt.Errorf("state of force policy is not declared: %s", from)
+		}
+		for _, to := range targets {
+			if !w.HasState(to) {
+				return fmt.Errorf("state of force policy is not declared: %s", to)
+			}
+		}
+	}
+	return nil
+}
+
+// WithForcePolicy allows OverrideSm as p does. ForceStateSm, which has no reason, still fails with ErrForbidden.
+// Without a policy, OverrideSm always fails with ErrForbidden.
+func WithForcePolicy(p ForcePolicy) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.force = &p
+	}
+}
+
+// WithoutForce makes ForceStateSm and OverrideSm fail with ErrForbidden.
+func WithoutForce() WorkflowOption {
+	return func(o *workflowOptions) {
+		o.noForce = true
+	}
+}
+
+// WithUnrestrictedForce allows ForceStateSm, which forces any declared state without a reason or a check.
+// Without it, ForceStateSm fails with ErrForbidden. It has no effect with WithoutForce or WithForcePolicy,
+// whose changes require a reason.
+func WithUnrestrictedForce() WorkflowOption {
+	return func(o *workflowOptions) {
+		o.unrestrictedForce = true
+	}
+}
+
+// checkForce returns an error if ForceStateSm is not allowed by w.
+func (w *Workflow) checkForce() error {
+	if w.opts.noForce {
+		return fmt.Errorf("%w: forcing states is disabled in %s", ErrForbidden, w.Name())
+	}
+	if w.opts.force != nil || !w.opts.unrestrictedForce {
+		return fmt.Errorf("%w: forcing states in %s requires a reason, see OverrideSm and WithUnrestrictedForce", ErrForbidden, w.Name())
+	}
+	return nil
+}
+
+// checkOverride returns an error if r is not allowed by w on an entity at from.
+// Without a ForcePolicy, nothing is allowed.
+func (w *Workflow) checkOverride(host, sm any, actor string, from types.TxState, r Override) error {
+	if w.opts.noForce {
+		return fmt.Errorf("%w: forcing states is disabled in %s", ErrForbidden, w.Name())
+	}
+	p := w.opts.force
+	if p == nil {
+		return fmt.Errorf("%w: overriding states in %s requires a force policy", ErrForbidden, w.Name())
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		return fmt.Errorf("%w: %s was overridden without a reason", ErrReasonRequired, from)
+	}
+	if p.Targets != nil && !containsState(p.Targets[from], r.To) {
+		return fmt.Errorf("%w: %q may not be forced to %s", ErrForbidden, from, r.To)
+	}
+
+	req := ForceRequest{Override: r, Workflow: w.Name(), Actor: actor, From: from}
+	req.Entity, req.EntityType, req.EntityID = w.entityOf(host, sm)
+	if err := p.Authorize(req); err != nil {
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return nil
+}
+
+func containsState(states []types.TxState, s types.TxState) bool {
+	for _, v := range states {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// forceState returns fn forcing the state machine to s unless its workflow forbids ForceStateSm.
+func (m *machine) forceState(s types.TxState) func(sm *internal.TxStateMachine) error {
+	return func(sm *internal.TxStateMachine) error {
+		if err := m.workflowSm().checkForce(); err != nil {
+			return err
+		}
+		return sm.ForceState(s)
+	}
+}
+
+// override returns fn forcing the state machine to r.To if the workflow allows r.
+func (m *machine) override(self any, r Override) func(sm *internal.TxStateMachine) error {
+	return func(sm *internal.TxStateMachine) error {
+		if err := m.workflowSm().checkOverride(m.host, self, m.actor, sm.State, r); err != nil {
+			return err
+		}
+		return sm.ForceState(r.To)
+	}
+}
@@ -0,0 +1,145 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+type logRecorder []TransitionLogRecord
+
+func (l *logRecorder) LogTransition(r TransitionLogRecord) {
+	*l = append(*l, r)
+}
+
+func allowForce(ForceRequest) error {
+	return nil
+}
+
+func Test_OverrideSm(t *testing.T) {
+	var logs logRecorder
+	w, err := newEventSourcedWorkflow(t).With(WithLogger(&logs), WithForcePolicy(ForcePolicy{Authorize: allowForce}))
+	require.Nil(t, err)
+
+	e := &TxStateMachineClock{State: types.ActiveTxState}
+	require.Nil(t, e.SetWorkflowSm(w))
+	err = e.OverrideSm(Override{To: types.PendingTxState})
+	require.ErrorIs(t, err, ErrReasonRequired)
+	require.Equal(t, ReasonRequiredError, ErrorKindOf(err))
+	require.NotNil(t, e.OverrideSm(Override{To: "unknown", Reason: "typo"}))
+	require.EqualValues(t, types.ActiveTxState, e.State)
+
+	require.Nil(t, e.OverrideSm(Override{To: types.PendingTxState, Reason: "approved by mistake"}))
+	require.EqualValues(t, types.PendingTxState, e.State)
+	require.Equal(t, []TransitionEvent{{
+		Sequence: 1, Kind: OverriddenEventKind, From: types.ActiveTxState, To: types.PendingTxState,
+		Reason: "approved by mistake", Version: 1, OccurredAt: *e.UpdatedAt,
+	}}, e.UncommittedEventsSm())
+
+	require.Len(t, logs, 3)
+	require.Equal(t, WarnLogLevel, logs[0].Level)
+	require.Equal(t, "transition failed", logs[0].Message)
+	require.Equal(t, ReasonRequiredError, logs[0].ErrorKind)
+	require.ErrorIs(t, logs[0].Err, ErrReasonRequired)
+	require.Equal(t, InvalidStateError, logs[1].ErrorKind)
+	require.Equal(t, WarnLogLevel, logs[2].Level)
+	require.Equal(t, "state overridden", logs[2].Message)
+	require.Equal(t, "approved by mistake", logs[2].Reason)
+	require.Nil(t, logs[2].Err)
+
+	// without a policy, OverrideSm is not allowed, and ForceStateSm only with WithUnrestrictedForce
+	w, err = newEventSourcedWorkflow(t).With(WithLogger(&logs))
+	require.Nil(t, err)
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.ErrorIs(t, e.OverrideSm(Override{To: types.ActiveTxState, Reason: "approved after all"}), ErrForbidden)
+	require.ErrorIs(t, e.ForceStateSm(types.ActiveTxState), ErrForbidden)
+	w, err = w.With(WithUnrestrictedForce())
+	require.Nil(t, err)
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.ForceStateSm(types.ActiveTxState))
+
+	require.Len(t, logs, 6)
+	require.Equal(t, WarnLogLevel, logs[3].Level)
+	require.Equal(t, "transition denied", logs[3].Message)
+	require.ErrorIs(t, logs[3].Err, ErrForbidden)
+}
+
+func Test_WithoutForce(t *testing.T) {
+	w, err := DefaultWorkflow().With(WithoutForce())
+	require.Nil(t, err)
+
+	e := &TxStateMachineClock{State: types.ActiveTxState}
+	require.Nil(t, e.SetWorkflowSm(w))
+	err = e.ForceStateSm(types.CanceledTxState)
+	require.ErrorIs(t, err, ErrForbidden)
+	require.Equal(t, ForbiddenError, ErrorKindOf(err))
+	require.ErrorIs(t, e.OverrideSm(Override{To: types.CanceledTxState, Reason: "cleanup"}), ErrForbidden)
+	require.EqualValues(t, types.ActiveTxState, e.State)
+	require.Zero(t, e.Version)
+
+	// disabling force wins over a policy
+	w, err = w.With(WithForcePolicy(ForcePolicy{Authorize: allowForce}))
+	require.Nil(t, err)
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.ErrorIs(t, e.OverrideSm(Override{To: types.CanceledTxState, Reason: "cleanup"}), ErrForbidden)
+}
+
+func Test_WithForcePolicy(t *testing.T) {
+	denied := errors.New("token is invalid")
+	var requests []ForceRequest
+	w, err := DefaultWorkflow().With(WithForcePolicy(ForcePolicy{
+		Authorize: func(r ForceRequest) error {
+			requests = append(requests, r)
+			if r.Token != "admin" {
+				return denied
+			}
+			return nil
+		},
+		Targets: map[types.TxState][]types.TxState{
+			types.ActiveTxState: {types.InactiveTxState, types.CanceledTxState},
+		},
+	}))
+	require.Nil(t, err)
+
+	o := &busOrder{ID: "o-1", TxStateMachineClock: TxStateMachineClock{State: types.ActiveTxState}}
+	require.Nil(t, o.SetWorkflowSm(w))
+	o.SetHostSm(o)
+	o.SetActorSm("alice")
+
+	require.ErrorIs(t, o.ForceStateSm(types.InactiveTxState), ErrForbidden)
+	require.ErrorIs(t, o.OverrideSm(Override{To: types.PendingTxState, Reason: "r", Token: "admin"}), ErrForbidden)
+	err = o.OverrideSm(Override{To: types.InactiveTxState, Reason: "r", Token: "guest"})
+	require.ErrorIs(t, err, ErrForbidden)
+	require.ErrorIs(t, err, denied)
+	require.EqualValues(t, types.ActiveTxState, o.State)
+
+	o.SetActorSm("alice")
+	require.Nil(t, o.OverrideSm(Override{To: types.InactiveTxState, Reason: "r", Token: "admin"}))
+	require.EqualValues(t, types.InactiveTxState, o.State)
+	// inactive is not listed in Targets
+	require.ErrorIs(t, o.OverrideSm(Override{To: types.ActiveTxState, Reason: "r", Token: "admin"}), ErrForbidden)
+
+	require.Len(t, requests, 2)
+	require.Equal(t, ForceRequest{
+		Override:   Override{To: types.InactiveTxState, Reason: "r", Token: "admin"},
+		Workflow:   "tx",
+		EntityType: "busOrder",
+		EntityID:   "o-1",
+		Entity:     o,
+		Actor:      "alice",
+		From:       types.ActiveTxState,
+	}, requests[1])
+}
+
+func Test_WithForcePolicy_invalid(t *testing.T) {
+	_, err := DefaultWorkflow().With(WithForcePolicy(ForcePolicy{}))
+	require.NotNil(t, err)
+
+	_, err = DefaultWorkflow().With(WithForcePolicy(ForcePolicy{
+		Authorize: allowForce,
+		Targets:   map[types.TxState][]types.TxState{types.ActiveTxState: {"unknown"}},
+	}))
+	require.NotNil(t, err)
+}
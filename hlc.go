@@ -0,0 +1,224 @@
+package state
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HLC is a hybrid logical clock timestamp: a physical time in milliseconds and a logical counter
+// ordering events within the same millisecond.
+// It is stored as a BIGINT whose order is the order of the timestamps.
+type HLC struct {
+	Wall    int64
+	Logical uint16
+}
+
+// HLCFromInt64 returns the timestamp stored as v, see HLC.Int64.
+func HLCFromInt64(v int64) HLC {
+	return HLC{Wall: v >> 16, Logical: uint16(v)}
+}
+
+// Int64 packs h into the wall time shifted by 16 bits and the logical counter.
+func (h HLC) Int64() int64 {
+	return h.Wall<<16 | int64(h.Logical)
+}
+
+func (h HLC) IsZero() bool {
+	return h == HLC{}
+}
+
+// Time returns the physical time of h.
+func (h HLC) Time() time.Time {
+	return time.UnixMilli(h.Wall)
+}
+
+// Compare returns -1 if h is before o, 1 if h is after o and 0 if they are equal.
+func (h HLC) Compare(o HLC) int {
+	switch {
+	case h.Wall < o.Wall:
+		return -1
+	case h.Wall > o.Wall:
+		return 1
+	case h.Logical < o.Logical:
+		return -1
+	case h.Logical > o.Logical:
+		return 1
+	}
+	return 0
+}
+
+func (h HLC) Before(o HLC) bool {
+	return h.Compare(o) < 0
+}
+
+func (h HLC) After(o HLC) bool {
+	return h.Compare(o) > 0
+}
+
+// String formats h as the wall time and the logical counter separated by a dot.
+func (h HLC) String() string {
+	return strconv.FormatInt(h.Wall, 10) + "." + strconv.FormatUint(uint64(h.Logical), 10)
+}
+
+// ParseHLC parses a timestamp formatted by HLC.String.
+func ParseHLC(s string) (HLC, error) {
+	wall, logical, ok := strings.Cut(s, ".")
+	if !ok {
+		return HLC{}, fmt.Errorf("invalid hybrid logical clock: %q", s)
+	}
+	w, err := strconv.ParseInt(wall, 10, 64)
+	if err != nil {
+		return HLC{}, fmt.Errorf("invalid hybrid logical clock: %q", s)
+	}
+	l, err := strconv.ParseUint(logical, 10, 16)
+	if err != nil {
+		return HLC{}, fmt.Errorf("invalid hybrid logical clock: %q", s)
+	}
+	return HLC{Wall: w, Logical: uint16(l)}, nil
+}
+
+func (h HLC) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+func (h *HLC) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*h = HLC{}
+		return nil
+	}
+	v, err := ParseHLC(s)
+	if err != nil {
+		return err
+	}
+	*h = v
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (h HLC) Value() (driver.Value, error) {
+	return h.Int64(), nil
+}
+
+// Scan implements sql.Scanner.
+func (h *HLC) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*h = HLC{}
+	case int64:
+		*h = HLCFromInt64(v)
+	case []byte:
+		return h.Scan(string(v))
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid hybrid logical clock: %q", v)
+		}
+		*h = HLCFromInt64(n)
+	default:
+		return fmt.Errorf("unable to scan %T into HLC", value)
+	}
+	return nil
+}
+
+// HLCSource issues hybrid logical clock timestamps of a node. It is safe for concurrent use.
+type HLCSource struct {
+	mu        sync.Mutex
+	clock     Clock
+	maxOffset time.Duration
+	last      HLC
+}
+
+// NewHLCSource returns a source reading physical time from c.
+// Update rejects timestamps more than maxOffset ahead of c; zero disables the check.
+func NewHLCSource(c Clock, maxOffset time.Duration) *HLCSource {
+	return &HLCSource{clock: c, maxOffset: maxOffset}
+}
+
+// Now returns a timestamp after every timestamp s has issued or merged.
+func (s *HLCSource) Now() HLC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pt := s.clock.Now().UnixMilli()
+	if pt > s.last.Wall {
+		s.last = HLC{Wall: pt}
+	} else {
+		s.last = s.last.next()
+	}
+	return s.last
+}
+
+// Update merges remote, a timestamp received from another node, and returns a timestamp after both.
+func (s *HLCSource) Update(remote HLC) (HLC, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pt := s.clock.Now().UnixMilli()
+	if s.maxOffset > 0 && remote.Wall-pt > s.maxOffset.Milliseconds() {
+		return HLC{}, fmt.Errorf("hybrid logical clock %s is %s ahead of the physical clock",
+			remote, time.Duration(remote.Wall-pt)*time.Millisecond)
+	}
+
+	switch last := s.last; {
+	case pt > last.Wall && pt > remote.Wall:
+		s.last = HLC{Wall: pt}
+	case last.Wall == remote.Wall:
+		if remote.Logical > last.Logical {
+			last.Logical = remote.Logical
+		}
+		s.last = last.next()
+	case last.Wall > remote.Wall:
+		s.last = last.next()
+	default:
+		s.last = remote.next()
+	}
+	return s.last, nil
+}
+
+// next returns the timestamp following h, moving to the next millisecond when the counter overflows.
+func (h HLC) next() HLC {
+	if h.Logical == ^uint16(0) {
+		return HLC{Wall: h.Wall + 1}
+	}
+	return HLC{Wall: h.Wall, Logical: h.Logical + 1}
+}
+
+// MergeHLC returns the later of a and b.
+func MergeHLC(a, b HLC) HLC {
+	if a.Before(b) {
+		return b
+	}
+	return a
+}
+
+var defaultHLCSource atomic.Pointer[HLCSource]
+
+func init() {
+	defaultHLCSource.Store(NewHLCSource(ClockFunc(func() time.Time { return DefaultClock().Now() }), 0))
+}
+
+// DefaultHLCSource returns the source TxHybridClock ticks with, unless its workflow has its own, see Workflow.HLCSource.
+// It reads physical time from DefaultClock and does not limit skew.
+func DefaultHLCSource() *HLCSource {
+	return defaultHLCSource.Load()
+}
+
+// SetDefaultHLCSource replaces the source returned by DefaultHLCSource for the whole process.
+func SetDefaultHLCSource(s *HLCSource) error {
+	if s == nil {
+		return errors.New("hybrid logical clock source is nil")
+	}
+	defaultHLCSource.Store(s)
+	return nil
+}
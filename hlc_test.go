@@ -0,0 +1,122 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HLCSource(t *testing.T) {
+	c := NewManualClock(time.UnixMilli(1000))
+	s := NewHLCSource(c, time.Second)
+
+	require.Equal(t, HLC{Wall: 1000}, s.Now())
+	require.Equal(t, HLC{Wall: 1000, Logical: 1}, s.Now())
+
+	// the physical clock going back does not make timestamps go back
+	c.Set(time.UnixMilli(900))
+	require.Equal(t, HLC{Wall: 1000, Logical: 2}, s.Now())
+
+	// a remote timestamp ahead is merged
+	v, err := s.Update(HLC{Wall: 1500, Logical: 7})
+	require.Nil(t, err)
+	require.Equal(t, HLC{Wall: 1500, Logical: 8}, v)
+	v, err = s.Update(HLC{Wall: 1500, Logical: 3})
+	require.Nil(t, err)
+	require.Equal(t, HLC{Wall: 1500, Logical: 9}, v)
+	v, err = s.Update(HLC{Wall: 1200})
+	require.Nil(t, err)
+	require.Equal(t, HLC{Wall: 1500, Logical: 10}, v)
+
+	// too much skew
+	_, err = s.Update(HLC{Wall: 5000})
+	require.NotNil(t, err)
+
+	c.Set(time.UnixMilli(2000))
+	v, err = s.Update(HLC{Wall: 1900, Logical: 4})
+	require.Nil(t, err)
+	require.Equal(t, HLC{Wall: 2000}, v)
+
+	// the counter overflows into the next millisecond
+	s.last = HLC{Wall: 2000, Logical: ^uint16(0)}
+	require.Equal(t, HLC{Wall: 2001}, s.Now())
+}
+
+func Test_HLC_encoding(t *testing.T) {
+	h := HLC{Wall: 1709251200000, Logical: 3}
+	require.Equal(t, h, HLCFromInt64(h.Int64()))
+	require.True(t, HLC{Wall: 1, Logical: 9}.Int64() < HLC{Wall: 2}.Int64())
+	require.Equal(t, -1, HLC{Wall: 1, Logical: 9}.Compare(HLC{Wall: 2}))
+	require.Equal(t, 1, HLC{Wall: 2, Logical: 1}.Compare(HLC{Wall: 2}))
+	require.Equal(t, h, MergeHLC(h, HLC{Wall: 5}))
+
+	b, err := json.Marshal(h)
+	require.Nil(t, err)
+	require.Equal(t, `"1709251200000.3"`, string(b))
+	var v HLC
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, h, v)
+	require.NotNil(t, json.Unmarshal([]byte(`"1709251200000"`), &v))
+
+	dv, err := h.Value()
+	require.Nil(t, err)
+	v = HLC{}
+	require.Nil(t, v.Scan(dv))
+	require.Equal(t, h, v)
+	require.Nil(t, v.Scan([]byte("65539")))
+	require.Equal(t, HLC{Wall: 1, Logical: 3}, v)
+	require.NotNil(t, v.Scan(1.5))
+}
+
+func Test_TxHybridClock(t *testing.T) {
+	c := NewManualClock(time.UnixMilli(1000))
+	require.Nil(t, SetDefaultHLCSource(NewHLCSource(c, 0)))
+	defer SetDefaultHLCSource(NewHLCSource(ClockFunc(func() time.Time { return DefaultClock().Now() }), 0))
+
+	a := &TxHybridClock{}
+	a.Tick()
+	a.Tick()
+	require.Equal(t, uint64(1), a.Version)
+	require.Equal(t, HLC{Wall: 1000}, a.HLC)
+
+	// b comes from a node whose clock is ahead
+	b := &TxHybridClock{Version: 4, HLC: HLC{Wall: 3000}}
+	require.Equal(t, -1, a.Compare(b))
+	require.Nil(t, b.Witness())
+	a.ResetTicked()
+	a.Tick()
+	require.Equal(t, HLC{Wall: 3000, Logical: 2}, a.HLC)
+	require.Equal(t, 1, a.Compare(b))
+}
+
+func Test_TxHybridClock_of_workflow(t *testing.T) {
+	c := NewManualClock(time.UnixMilli(1000))
+	w, err := DefaultWorkflow().With(WithClock(c))
+	require.Nil(t, err)
+	require.NotSame(t, DefaultHLCSource(), w.HLCSource())
+	require.Same(t, DefaultHLCSource(), DefaultWorkflow().HLCSource())
+	// a workflow built on w keeps its source
+	w2, err := w.With(WithEventSourcing())
+	require.Nil(t, err)
+	require.Same(t, w.HLCSource(), w2.HLCSource())
+
+	var a Stateful[TxHybridClock, *TxHybridClock]
+	require.Nil(t, a.SetWorkflowSm(w))
+	require.Nil(t, a.PendingSm())
+	require.Equal(t, HLC{Wall: 1000}, a.Clock.HLC)
+	require.Equal(t, time.UnixMilli(1000), *a.Clock.UpdatedAt)
+
+	// b comes from a node whose clock is ahead, and is witnessed by the source of the workflow
+	b := &TxHybridClock{Version: 4, HLC: HLC{Wall: 3000}}
+	require.Nil(t, b.WitnessWith(w.HLCSource()))
+	a.ResetTicked()
+	require.Nil(t, a.ApproveSm())
+	require.Equal(t, HLC{Wall: 3000, Logical: 2}, a.Clock.HLC)
+
+	s := NewHLCSource(c, 0)
+	w, err = w.With(WithHLCSource(s), WithClock(SystemClock))
+	require.Nil(t, err)
+	require.Same(t, s, w.HLCSource())
+}
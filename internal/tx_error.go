@@ -0,0 +1,47 @@
+package internal
+
+import "errors"
+
+// TxErrorKind classifies why a transition failed.
+type TxErrorKind string
+
+const (
+	// NotPermittedTxError is an event the workflow does not allow in the current state.
+	NotPermittedTxError TxErrorKind = "not_permitted"
+	// GuardTxError is a transition rejected by its guard.
+	GuardTxError TxErrorKind = "guard"
+	// InvalidStateTxError is a state the workflow does not declare.
+	InvalidStateTxError TxErrorKind = "invalid_state"
+	// UninitializedTxError is a state machine whose current state is unknown.
+	UninitializedTxError TxErrorKind = "uninitialized"
+)
+
+// ErrUninitialized is wrapped by the errors of transitions attempted before the state machine has a state.
+var ErrUninitialized = errors.New("state is not initialized")
+
+// TxError is an error of a transition. Its message is the message of Err.
+type TxError struct {
+	Kind TxErrorKind
+	Err  error
+}
+
+func (e *TxError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+func newTxError(kind TxErrorKind, err error) error {
+	return &TxError{Kind: kind, Err: err}
+}
+
+// TxErrorKindOf returns the kind of err, or an empty kind if err is not a TxError.
+func TxErrorKindOf(err error) TxErrorKind {
+	var e *TxError
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return ""
+}
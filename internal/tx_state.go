@@ -1,229 +1,61 @@
 package internal
 
 import (
-	"errors"
-
 	"github.com/wonksing/state/types"
 )
 
-var (
-	_pendingTxState         = newPendingTxState(types.ActiveTxState, types.CanceledTxState)
-	_modifyPendingTxState   = newModifyPendingTxState(types.ActiveTxState, types.ActiveTxState)
-	_activeTxState          = newActiveTxState(types.ActiveTxState, types.CanceledTxState)
-	_canceledTxState        = newCanceledTxState(types.CanceledTxState, types.CanceledTxState)
-	_removePendingTxState   = newRemovePendingTxState(types.RemovedTxState, types.ActiveTxState)
-	_removedTxState         = newRemovedTxState(types.RemovedTxState, types.RemovedTxState)
-	_inactivePendingTxState = newInactivePendingTxState(types.InactiveTxState, types.ActiveTxState)
-	_inactiveTxState        = newInactiveTxState(types.InactiveTxState, types.InactiveTxState)
-	_activePendingTxState   = newActivePendingTxState(types.ActiveTxState, types.InactiveTxState)
-)
-
-// newPendingTxState
-func newPendingTxState(approved, canceled types.TxState) *pendingTxState {
-	return &pendingTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type pendingTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *pendingTxState) Approve() (next types.TxState, err error) {
-	next = s.approvedState
-	return
-}
+// DefaultTxWorkflow is the lifecycle every TxStateMachine follows unless another workflow is given.
+//
+//	pending > active(canceled)
+//	active > modify_pending > active
+//	active > remove_pending > removed(active)
+//	active > inactive_pending > inactive(active)
+//	inactive > active_pending > active(inactive)
+var DefaultTxWorkflow = mustNewTxWorkflow("tx", types.PendingTxState, defaultTxStates, defaultTxTransitions)
 
-// Cancel changes pending to canceled state
-func (s *pendingTxState) Cancel() (next types.TxState, err error) {
-	next = s.canceledState
-	return
+var defaultTxStates = []types.TxStateSpec{
+	{Name: types.PendingTxState, Category: types.PendingTxCategory},
+	{Name: types.ModifyPendingTxState, Category: types.PendingTxCategory},
+	{Name: types.ActiveTxState, Category: types.ActiveTxCategory},
+	{Name: types.CanceledTxState, Category: types.ClosedTxCategory, Terminal: true},
+	{Name: types.RemovePendingTxState, Category: types.PendingTxCategory},
+	{Name: types.RemovedTxState, Category: types.ClosedTxCategory, Terminal: true},
+	{Name: types.InactivePendingTxState, Category: types.PendingTxCategory},
+	{Name: types.InactiveTxState, Category: types.InactiveTxCategory},
+	{Name: types.ActivePendingTxState, Category: types.PendingTxCategory},
 }
 
-// newTxPendingState
-func newModifyPendingTxState(approved, canceled types.TxState) *modifyPendingTxState {
-	return &modifyPendingTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
+var defaultTxTransitions = []types.TxTransition{
+	{From: "", Event: types.PendingTxEvent, To: types.PendingTxState},
 
-type modifyPendingTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
+	{From: types.PendingTxState, Event: types.ApproveTxEvent, To: types.ActiveTxState},
+	{From: types.PendingTxState, Event: types.CancelTxEvent, To: types.CanceledTxState},
+	// a pending entity has not been approved yet, so there is nothing to modify
+	{From: types.PendingTxState, Event: types.ModifyPendingTxEvent, To: types.PendingTxState},
 
-func (s *modifyPendingTxState) Approve() (next types.TxState, err error) {
-	next = s.approvedState
-	return
-}
+	{From: types.ActiveTxState, Event: types.ModifyPendingTxEvent, To: types.ModifyPendingTxState},
+	{From: types.ActiveTxState, Event: types.RemovePendingTxEvent, To: types.RemovePendingTxState},
+	{From: types.ActiveTxState, Event: types.InactivePendingTxEvent, To: types.InactivePendingTxState},
 
-func (s *modifyPendingTxState) Cancel() (next types.TxState, err error) {
-	next = s.canceledState
-	return
-}
+	{From: types.ModifyPendingTxState, Event: types.ApproveTxEvent, To: types.ActiveTxState},
+	{From: types.ModifyPendingTxState, Event: types.CancelTxEvent, To: types.ActiveTxState},
 
-// newActiveTxState
-func newActiveTxState(approved, canceled types.TxState) *activeTxState {
-	return &activeTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
+	{From: types.RemovePendingTxState, Event: types.ApproveTxEvent, To: types.RemovedTxState},
+	{From: types.RemovePendingTxState, Event: types.CancelTxEvent, To: types.ActiveTxState},
 
-type activeTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
+	{From: types.InactivePendingTxState, Event: types.ApproveTxEvent, To: types.InactiveTxState},
+	{From: types.InactivePendingTxState, Event: types.CancelTxEvent, To: types.ActiveTxState},
 
-func (s *activeTxState) Approve() (next types.TxState, err error) {
-	err = errors.New("already active")
-	return
-}
+	{From: types.InactiveTxState, Event: types.ActivePendingTxEvent, To: types.ActivePendingTxState},
 
-// Cancel always returns error when state is active
-func (s *activeTxState) Cancel() (next types.TxState, err error) {
-	err = errors.New("cannot cancel active state")
-	// next = s.canceledState
-	return
+	{From: types.ActivePendingTxState, Event: types.ApproveTxEvent, To: types.ActiveTxState},
+	{From: types.ActivePendingTxState, Event: types.CancelTxEvent, To: types.InactiveTxState},
 }
 
-// newCanceledTxState
-func newCanceledTxState(approved, canceled types.TxState) *canceledTxState {
-	return &canceledTxState{
-		approvedState: approved,
-		canceledState: canceled,
+func mustNewTxWorkflow(name string, initial types.TxState, states []types.TxStateSpec, transitions []types.TxTransition) *TxWorkflow {
+	w, err := NewTxWorkflow(name, initial, states, nil, transitions, nil)
+	if err != nil {
+		panic(err)
 	}
-}
-
-type canceledTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *canceledTxState) Approve() (next types.TxState, err error) {
-	err = errors.New("already canceled")
-	return
-}
-
-func (s *canceledTxState) Cancel() (next types.TxState, err error) {
-	err = errors.New("cannot cancel canceled state")
-	return
-}
-
-// newRemovePendingTxState
-func newRemovePendingTxState(approved, canceled types.TxState) *removePendingTxState {
-	return &removePendingTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type removePendingTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *removePendingTxState) Approve() (next types.TxState, err error) {
-	next = s.approvedState
-	return
-}
-
-func (s *removePendingTxState) Cancel() (next types.TxState, err error) {
-	next = s.canceledState
-	return
-}
-
-// newRemovedTxState
-func newRemovedTxState(approved, canceled types.TxState) *removedTxState {
-	return &removedTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type removedTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *removedTxState) Approve() (next types.TxState, err error) {
-	err = errors.New("already removed")
-	return
-}
-
-func (s *removedTxState) Cancel() (next types.TxState, err error) {
-	err = errors.New("already removed")
-	return
-}
-
-// newInactivePendingTxState
-func newInactivePendingTxState(approved, canceled types.TxState) *inactivePendingTxState {
-	return &inactivePendingTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type inactivePendingTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *inactivePendingTxState) Approve() (next types.TxState, err error) {
-	next = s.approvedState
-	return
-}
-
-func (s *inactivePendingTxState) Cancel() (next types.TxState, err error) {
-	next = s.canceledState
-	return
-}
-
-// newInactiveTxState
-func newInactiveTxState(approved, canceled types.TxState) *inactiveTxState {
-	return &inactiveTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type inactiveTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *inactiveTxState) Approve() (next types.TxState, err error) {
-	err = errors.New("already inactive")
-	return
-}
-
-func (s *inactiveTxState) Cancel() (next types.TxState, err error) {
-	err = errors.New("already inactive")
-	return
-}
-
-// newActivePendingTxState
-func newActivePendingTxState(approved, canceled types.TxState) *activePendingTxState {
-	return &activePendingTxState{
-		approvedState: approved,
-		canceledState: canceled,
-	}
-}
-
-type activePendingTxState struct {
-	approvedState types.TxState
-	canceledState types.TxState
-}
-
-func (s *activePendingTxState) Approve() (next types.TxState, err error) {
-	next = s.approvedState
-	return
-}
-
-func (s *activePendingTxState) Cancel() (next types.TxState, err error) {
-	next = s.canceledState
-	return
+	return w
 }
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -134,3 +135,56 @@ func (e *testEntity) Approve() error {
 func (e *testEntity) Cancel() error {
 	return e.StateMachine.Cancel()
 }
+
+func Test_TxStateMachine_Fire_guard(t *testing.T) {
+	allowed := false
+	w, err := NewTxWorkflow("w", "open",
+		[]types.TxStateSpec{{Name: "open"}, {Name: "closed", Terminal: true}},
+		nil,
+		[]types.TxTransition{{From: "open", Event: "close", To: "closed", Guard: "allowed"}},
+		map[string]TxGuard{"allowed": func(types.TxTransition) error {
+			if !allowed {
+				return errors.New("not allowed")
+			}
+			return nil
+		}})
+	require.Nil(t, err)
+
+	m, err := NewTxStateMachineWithWorkflow(w, "open", nil)
+	require.Nil(t, err)
+
+	err = m.Fire("close")
+	require.NotNil(t, err)
+	require.EqualValues(t, "open", m.State)
+
+	allowed = true
+	err = m.Fire("close")
+	require.Nil(t, err)
+	require.EqualValues(t, "closed", m.State)
+
+	err = m.Fire("close")
+	require.NotNil(t, err)
+}
+
+func Test_TxStateMachine_SetState_request_events(t *testing.T) {
+	w, err := NewTxWorkflow("w", types.PendingTxState,
+		[]types.TxStateSpec{{Name: types.PendingTxState}, {Name: types.ModifyPendingTxState}, {Name: types.ActiveTxState}},
+		nil,
+		[]types.TxTransition{
+			{From: types.ActiveTxState, Event: types.ModifyPendingTxEvent, To: types.ModifyPendingTxState},
+			// an event named after a state that is not requested
+			{From: types.PendingTxState, Event: "active", To: types.ActiveTxState},
+		}, nil)
+	require.Nil(t, err)
+
+	m, err := NewTxStateMachineWithWorkflow(w, types.PendingTxState, nil)
+	require.Nil(t, err)
+
+	err = m.SetState(types.ActiveTxState)
+	require.Equal(t, NotPermittedTxError, TxErrorKindOf(err))
+	require.EqualValues(t, types.PendingTxState, m.State)
+
+	require.Nil(t, m.Fire("active"))
+	require.Nil(t, m.SetState(types.ModifyPendingTxState))
+	require.EqualValues(t, types.ModifyPendingTxState, m.State)
+}
@@ -2,49 +2,47 @@ package internal
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/wonksing/state/types"
 )
 
-type TxStateTransitioner interface {
-	Approve() (next types.TxState, err error)
-	Cancel() (next types.TxState, err error)
-}
-
 type TxStateAssignor interface {
 	AssignStateCallback(s types.TxState) error
 }
 
 // NewTxStateMachine
 func NewTxStateMachine(initState types.TxState, setter TxStateAssignor) (*TxStateMachine, error) {
-	err := validateTxState(initState)
+	return NewTxStateMachineWithWorkflow(DefaultTxWorkflow, initState, setter)
+}
+
+// NewTxStateMachineWithWorkflow returns a TxStateMachine following the transitions of w.
+// An empty initState leaves it uninitialized, see Init.
+func NewTxStateMachineWithWorkflow(w *TxWorkflow, initState types.TxState, setter TxStateAssignor) (*TxStateMachine, error) {
+	if w == nil {
+		return nil, errors.New("workflow is nil")
+	}
+	if initState == "" {
+		return &TxStateMachine{w: w, setter: setter}, nil
+	}
+	err := w.Validate(initState)
 	if err != nil {
 		return nil, err
 	}
 
 	machine := &TxStateMachine{
 		State:  initState,
+		w:      w,
 		setter: setter,
 	}
 
-	machine.m = make(map[types.TxState]TxStateTransitioner)
-	machine.m[types.PendingTxState] = _pendingTxState
-	machine.m[types.ModifyPendingTxState] = _modifyPendingTxState
-	machine.m[types.ActiveTxState] = _activeTxState
-	machine.m[types.CanceledTxState] = _canceledTxState
-	machine.m[types.RemovePendingTxState] = _removePendingTxState
-	machine.m[types.RemovedTxState] = _removedTxState
-	machine.m[types.InactivePendingTxState] = _inactivePendingTxState
-	machine.m[types.InactiveTxState] = _inactiveTxState
-	machine.m[types.ActivePendingTxState] = _activePendingTxState
-
 	err = machine.SetState(initState)
 	return machine, err
 }
 
 type TxStateMachine struct {
 	State  types.TxState
-	m      map[types.TxState]TxStateTransitioner
+	w      *TxWorkflow
 	setter TxStateAssignor
 }
 
@@ -92,131 +90,91 @@ func (m TxStateMachine) IsActivePending() bool {
 	return m.State == types.ActivePendingTxState
 }
 
+// IsPendingKind reports whether the current state belongs to types.PendingTxCategory.
 func (m TxStateMachine) IsPendingKind() bool {
-	if m.State == types.PendingTxState || m.State == types.ActivePendingTxState ||
-		m.State == types.ModifyPendingTxState || m.State == types.RemovePendingTxState || m.State == types.InactivePendingTxState {
-		return true
-	}
-	return false
+	return m.IsCategory(types.PendingTxCategory)
 }
 
-// SetState sets newState to m.State.
-// If newState is one of types.ModifyPendingTxState, types.RemovePendingTxState or types.InactivePendingTxState,
-// m.State should be types.ActiveTxState.
+// IsCategory reports whether the current state belongs to c.
+func (m TxStateMachine) IsCategory(c types.TxCategory) bool {
+	s, ok := m.w.State(m.State)
+	return ok && s.Category == c
+}
+
+// Workflow returns the transition table m follows.
+func (m TxStateMachine) Workflow() *TxWorkflow {
+	return m.w
+}
+
+// SetState sets newState to m.State by firing the request event of newState, see types.RequestTxEvent.
 // If newState is equal to m.State, it returns nil.
 func (m *TxStateMachine) SetState(newState types.TxState) error {
-	err := validateTxState(newState)
+	err := m.w.Validate(newState)
 	if err != nil {
 		return err
 	}
 
 	if newState == m.State {
-		if m.setter != nil {
-			return m.setter.AssignStateCallback(newState)
-		}
-		return nil
+		return m.assign(newState)
 	}
 
-	switch newState {
-	case types.ModifyPendingTxState:
-		switch m.State {
-		case types.ActiveTxState:
-		case types.PendingTxState:
-			return nil
-		default:
-			return errors.New("unable to set state")
-		}
-	case types.RemovePendingTxState:
-		switch m.State {
-		case types.ActiveTxState:
-		default:
-			return errors.New("unable to set state")
-		}
-	case types.InactivePendingTxState:
-		switch m.State {
-		case types.ActiveTxState:
-		default:
-			return errors.New("unable to set state")
-		}
-	case types.ActivePendingTxState:
-		switch m.State {
-		case types.InactiveTxState:
-		default:
-			return errors.New("unable to set state")
-		}
-	case types.PendingTxState:
-		if string(m.State) != "" {
-			return errors.New("unable to set state")
-		}
-	default:
-		return errors.New("unable to set state")
+	ev, ok := types.RequestTxEvent(newState)
+	if ok {
+		_, ok = m.w.Lookup(m.State, ev)
 	}
-
-	m.State = newState
-	if m.setter != nil {
-		return m.setter.AssignStateCallback(newState)
+	if !ok {
+		if m.State == "" {
+			return newTxError(UninitializedTxError, fmt.Errorf("%w: unable to set state", ErrUninitialized))
+		}
+		return newTxError(NotPermittedTxError, errors.New("unable to set state"))
 	}
-	return nil
+	return m.Fire(ev)
 }
 
 func (m *TxStateMachine) ForceState(newState types.TxState) error {
-	err := validateTxState(newState)
+	err := m.w.Validate(newState)
 	if err != nil {
 		return err
 	}
 
 	m.State = newState
-	if m.setter != nil {
-		return m.setter.AssignStateCallback(newState)
-	}
-	return nil
+	return m.assign(newState)
 }
 
-func (m *TxStateMachine) Approve() error {
-	if v, ok := m.m[m.State]; ok {
-		next, err := v.Approve()
-		if err != nil {
-			return err
-		}
-		m.State = next
-		if m.setter != nil {
-			return m.setter.AssignStateCallback(m.State)
-		}
-		return nil
+// Init sets s to m.State if m has no state yet.
+func (m *TxStateMachine) Init(s types.TxState) error {
+	if m.State != "" {
+		return newTxError(NotPermittedTxError, fmt.Errorf("state is already initialized to %s", m.State))
+	}
+	if err := m.w.Validate(s); err != nil {
+		return err
 	}
 
-	return errors.New("current state was not initialized")
+	m.State = s
+	return m.assign(s)
+}
+
+func (m *TxStateMachine) Approve() error {
+	return m.Fire(types.ApproveTxEvent)
 }
 
 func (m *TxStateMachine) Cancel() error {
-	if v, ok := m.m[m.State]; ok {
-		next, err := v.Cancel()
-		if err != nil {
-			return err
-		}
-		m.State = next
-		if m.setter != nil {
-			return m.setter.AssignStateCallback(m.State)
-		}
-		return nil
+	return m.Fire(types.CancelTxEvent)
+}
+
+// Fire moves m to the state that ev leads to from the current state.
+func (m *TxStateMachine) Fire(ev types.TxEvent) error {
+	t, err := m.w.Next(m.State, ev)
+	if err != nil {
+		return err
 	}
+	m.State = t.To
+	return m.assign(m.State)
+}
 
-	return errors.New("current state was not initialized")
-}
-
-func validateTxState(state types.TxState) (err error) {
-	switch state {
-	case types.PendingTxState:
-	case types.ModifyPendingTxState:
-	case types.ActiveTxState:
-	case types.CanceledTxState:
-	case types.RemovePendingTxState:
-	case types.RemovedTxState:
-	case types.InactivePendingTxState:
-	case types.InactiveTxState:
-	case types.ActivePendingTxState:
-	default:
-		return errors.New("state is invalid")
+func (m *TxStateMachine) assign(s types.TxState) error {
+	if m.setter != nil {
+		return m.setter.AssignStateCallback(s)
 	}
 	return nil
 }
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wonksing/state/types"
+)
+
+// TxGuard reports whether t may happen. A non-nil error rejects t.
+type TxGuard func(t types.TxTransition) error
+
+// TxWorkflow is a transition table.
+type TxWorkflow struct {
+	Name        string
+	Initial     types.TxState
+	States      []types.TxStateSpec
+	Events      []types.TxEvent
+	Transitions []types.TxTransition
+	Guards      map[string]TxGuard
+
+	states map[types.TxState]types.TxStateSpec
+	next   map[types.TxState]map[types.TxEvent]types.TxTransition
+}
+
+// NewTxWorkflow builds the lookup tables of a workflow.
+// It rejects transitions that refer to undeclared states, events or guards, and events mapped to more than one target.
+// If events is nil, the events are collected from transitions.
+func NewTxWorkflow(name string, initial types.TxState, states []types.TxStateSpec, events []types.TxEvent, transitions []types.TxTransition, guards map[string]TxGuard) (*TxWorkflow, error) {
+	w := &TxWorkflow{
+		Name:        name,
+		Initial:     initial,
+		States:      states,
+		Events:      events,
+		Transitions: transitions,
+		Guards:      guards,
+		states:      make(map[types.TxState]types.TxStateSpec),
+		next:        make(map[types.TxState]map[types.TxEvent]types.TxTransition),
+	}
+
+	for _, s := range states {
+		if s.Name == "" {
+			return nil, errors.New("state name must not be empty")
+		}
+		if _, ok := w.states[s.Name]; ok {
+			return nil, fmt.Errorf("duplicated state: %s", s.Name)
+		}
+		w.states[s.Name] = s
+	}
+	if _, ok := w.states[initial]; !ok {
+		return nil, fmt.Errorf("initial state is not declared: %q", initial)
+	}
+
+	declared := make(map[types.TxEvent]bool)
+	for _, ev := range events {
+		if ev == "" {
+			return nil, errors.New("event name must not be empty")
+		}
+		if declared[ev] {
+			return nil, fmt.Errorf("duplicated event: %s", ev)
+		}
+		declared[ev] = true
+	}
+
+	for _, t := range transitions {
+		if t.Event == "" {
+			return nil, fmt.Errorf("event of transition from %q must not be empty", t.From)
+		}
+		if events == nil {
+			if !declared[t.Event] {
+				declared[t.Event] = true
+				w.Events = append(w.Events, t.Event)
+			}
+		} else if !declared[t.Event] {
+			return nil, fmt.Errorf("event is not declared: %s", t.Event)
+		}
+		if _, ok := w.states[t.From]; !ok && t.From != "" {
+			return nil, fmt.Errorf("state is not declared: %s", t.From)
+		}
+		if _, ok := w.states[t.To]; !ok {
+			return nil, fmt.Errorf("state is not declared: %s", t.To)
+		}
+		if t.Guard != "" {
+			if _, ok := guards[t.Guard]; !ok {
+				return nil, fmt.Errorf("guard is not registered: %s", t.Guard)
+			}
+		}
+
+		m, ok := w.next[t.From]
+		if !ok {
+			m = make(map[types.TxEvent]types.TxTransition)
+			w.next[t.From] = m
+		}
+		if v, ok := m[t.Event]; ok {
+			return nil, fmt.Errorf("%s from %q leads to both %s and %s", t.Event, t.From, v.To, t.To)
+		}
+		m[t.Event] = t
+	}
+	return w, nil
+}
+
+// HasState reports whether s is declared.
+func (w *TxWorkflow) HasState(s types.TxState) bool {
+	_, ok := w.states[s]
+	return ok
+}
+
+// State returns the declaration of s.
+func (w *TxWorkflow) State(s types.TxState) (types.TxStateSpec, bool) {
+	v, ok := w.states[s]
+	return v, ok
+}
+
+// Validate returns an error if s is not declared.
+func (w *TxWorkflow) Validate(s types.TxState) error {
+	if !w.HasState(s) {
+		return newTxError(InvalidStateTxError, errors.New("state is invalid"))
+	}
+	return nil
+}
+
+// Lookup returns the transition fired by ev from the state from.
+func (w *TxWorkflow) Lookup(from types.TxState, ev types.TxEvent) (types.TxTransition, bool) {
+	t, ok := w.next[from][ev]
+	return t, ok
+}
+
+// Outgoing returns transitions leaving from in declaration order.
+func (w *TxWorkflow) Outgoing(from types.TxState) []types.TxTransition {
+	var res []types.TxTransition
+	for _, t := range w.Transitions {
+		if t.From == from {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Next returns the transition fired by ev from the state from after checking its guard.
+func (w *TxWorkflow) Next(from types.TxState, ev types.TxEvent) (types.TxTransition, error) {
+	if from != "" && !w.HasState(from) {
+		return types.TxTransition{}, newTxError(UninitializedTxError, errors.New("current state was not initialized"))
+	}
+	t, ok := w.Lookup(from, ev)
+	if !ok {
+		if from == "" {
+			return t, newTxError(UninitializedTxError, fmt.Errorf("%w: %s is not permitted", ErrUninitialized, ev))
+		}
+		return t, newTxError(NotPermittedTxError, fmt.Errorf("%s is not permitted in %s state", ev, from))
+	}
+	if t.Guard != "" {
+		if err := w.Guards[t.Guard](t); err != nil {
+			return t, newTxError(GuardTxError, err)
+		}
+	}
+	return t, nil
+}
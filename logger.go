@@ -0,0 +1,88 @@
+package state
+
+import (
+	"github.com/wonksing/state/types"
+)
+
+type LogLevel int
+
+const (
+	// InfoLogLevel is used for transitions allowed by the workflow.
+	InfoLogLevel LogLevel = iota
+	// WarnLogLevel is used for ForceStateSm and OverrideSm, which skip the rules of the workflow,
+	// and for transitions denied or failed.
+	WarnLogLevel
+)
+
+// TransitionLogRecord is a transition written to a TransitionLogger.
+type TransitionLogRecord struct {
+	Level   LogLevel
+	Message string
+
+	Workflow   string
+	EntityType string
+	EntityID   string
+	Kind       TransitionEventKind
+	Event      types.TxEvent
+	From       types.TxState
+	To         types.TxState
+	Version    uint64
+	Actor      string
+	Reason     string
+	Assignee   string
+
+	// ErrorKind and Err are why a transition was denied or failed.
+	ErrorKind TransitionErrorKind
+	Err       error
+}
+
+// TransitionLogger writes records of transitions, e.g. with the adapter of package stateslog.
+type TransitionLogger interface {
+	LogTransition(r TransitionLogRecord)
+}
+
+// WithLogger writes a record to l for every transition attempted on entities following the workflow.
+// Transitions denied or failed are written at WarnLogLevel with their error.
+func WithLogger(l TransitionLogger) WorkflowOption {
+	return WithObserver(TransitionObserverFunc(func(o TransitionOutcome) {
+		r := TransitionLogRecord{
+			Level:      InfoLogLevel,
+			Message:    "state transitioned",
+			Workflow:   o.Workflow,
+			EntityType: o.EntityType,
+			EntityID:   o.EntityID,
+			Kind:       o.Kind,
+			Event:      o.Event,
+			From:       o.From,
+			To:         o.To,
+			Version:    o.Version,
+			Actor:      o.Actor,
+			Reason:     o.Reason,
+			Assignee:   o.Assignee,
+		}
+		if o.Result != SucceededResult {
+			r.Level, r.Message = WarnLogLevel, "transition failed"
+			if o.ErrorKind == ForbiddenError {
+				r.Message = "transition denied"
+			}
+			r.ErrorKind, r.Err = o.ErrorKind, o.Err
+			l.LogTransition(r)
+			return
+		}
+		switch o.Kind {
+		case ForcedEventKind:
+			r.Level = WarnLogLevel
+			r.Message = "state forced"
+		case OverriddenEventKind:
+			r.Level = WarnLogLevel
+			r.Message = "state overridden"
+		case UndoneEventKind:
+			r.Message = "approval undone"
+		case ImportedEventKind:
+			r.Message = "state imported"
+		case AssignedEventKind:
+			r.Message = "entity assigned"
+		}
+		l.LogTransition(r)
+	}))
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// machine is the part of an entity with a state machine that is not persisted.
+// core passes its State and the entity, the assignor of State, to every call.
+type machine struct {
+	stateMachine *internal.TxStateMachine
+	workflow     *Workflow
+	host         any
+	actor        string
+
+	sequence    uint64
+	uncommitted []TransitionEvent
+
+	unit int
+	// unitSaved is the *unitSaved of the entity while a unit of work is active.
+	unitSaved any
+	// unpublished are the transitions made during a unit of work, published when it commits.
+	unpublished []func()
+}
+
+func (m *machine) workflowSm() *Workflow {
+	if m.workflow == nil {
+		return DefaultWorkflow()
+	}
+	return m.workflow
+}
+
+func (m *machine) setWorkflow(state types.TxState, w *Workflow) error {
+	if w == nil {
+		return errors.New("workflow is nil")
+	}
+	if state != "" && !w.HasState(state) {
+		return errors.New("state is invalid")
+	}
+	m.workflow = w
+	m.stateMachine = nil
+	return nil
+}
+
+// sync checks and initializes m.stateMachine. It never changes state, which is empty until the entity is initialized.
+// state wins over the state of m.stateMachine, so it may be assigned directly, e.g. when it is loaded from a database.
+func (m *machine) sync(state *types.TxState, self internal.TxStateAssignor) error {
+	if m.stateMachine == nil {
+		var err error
+		m.stateMachine, err = internal.NewTxStateMachineWithWorkflow(m.workflowSm().table, *state, self)
+		return err
+	}
+	if m.stateMachine.State != *state {
+		if *state != "" {
+			if err := m.stateMachine.Workflow().Validate(*state); err != nil {
+				return err
+			}
+		}
+		m.stateMachine.State = *state
+	}
+	return nil
+}
+
+// ask returns fn of the state machine, or false if it cannot be initialized.
+// It has no side effect on state.
+func (m *machine) ask(state *types.TxState, self internal.TxStateAssignor, fn func(sm *internal.TxStateMachine) bool) bool {
+	if err := m.sync(state, self); err != nil {
+		return false
+	}
+	return fn(m.stateMachine)
+}
+
+// apply runs fn on the state machine, then calls commit, which ticks, sets o.Version
+// and returns the change to publish. o is the Kind, Event and Reason of the change.
+// The attempt is reported to the observers of the workflow either way, and clears the actor.
+func (m *machine) apply(state *types.TxState, self internal.TxStateAssignor, o TransitionOutcome,
+	fn func(sm *internal.TxStateMachine) error, commit func(o *TransitionOutcome) TransitionEvent) error {
+	defer func() { m.actor = "" }()
+	w := m.workflowSm()
+	o.From, o.Actor, o.Start = *state, m.actor, time.Now()
+	if err := m.sync(state, self); err != nil {
+		w.observe(m.host, self, o, err)
+		return err
+	}
+	o.From = *state
+	if err := w.authorize(m.host, self, o); err != nil {
+		w.observe(m.host, self, o, err)
+		return err
+	}
+	if err := fn(m.stateMachine); err != nil {
+		w.observe(m.host, self, o, err)
+		return err
+	}
+	o.To = *state
+	v := commit(&o)
+	w.observe(m.host, self, o, nil)
+	m.publish(w, self, o.Actor, v)
+	return nil
+}
+
+// publish publishes v to the bus of w, or once the unit of work of the entity commits if one is active.
+func (m *machine) publish(w *Workflow, self any, actor string, v TransitionEvent) {
+	host := m.host
+	if m.unit > 0 {
+		m.unpublished = append(m.unpublished, func() { w.publish(host, self, actor, v) })
+		return
+	}
+	w.publish(host, self, actor, v)
+}
+
+// flush publishes the transitions made during a unit of work.
+func (m *machine) flush() {
+	fns := m.unpublished
+	m.unpublished = nil
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// initState initializes the state machine at s, or at the initial state of its workflow if s is empty.
+func initState(s types.TxState) func(sm *internal.TxStateMachine) error {
+	return func(sm *internal.TxStateMachine) error {
+		if s == "" {
+			s = sm.Workflow().Initial
+		}
+		return sm.Init(s)
+	}
+}
+
+func setState(s types.TxState) func(sm *internal.TxStateMachine) error {
+	return func(sm *internal.TxStateMachine) error {
+		return sm.SetState(s)
+	}
+}
+
+func fire(ev types.TxEvent) func(sm *internal.TxStateMachine) error {
+	return func(sm *internal.TxStateMachine) error {
+		return sm.Fire(ev)
+	}
+}
+
+func equal(s types.TxState) func(sm *internal.TxStateMachine) bool {
+	return func(sm *internal.TxStateMachine) bool {
+		return sm.Equal(s)
+	}
+}
+
+func isCategory(c types.TxCategory) func(sm *internal.TxStateMachine) bool {
+	return func(sm *internal.TxStateMachine) bool {
+		return sm.IsCategory(c)
+	}
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+type TransitionResult string
+
+const (
+	SucceededResult TransitionResult = "succeeded"
+	FailedResult    TransitionResult = "failed"
+)
+
+// TransitionErrorKind classifies why a transition failed.
+type TransitionErrorKind string
+
+const (
+	// NotPermittedError is an event the workflow does not allow in the current state.
+	NotPermittedError TransitionErrorKind = TransitionErrorKind(internal.NotPermittedTxError)
+	// GuardError is a transition rejected by its guard.
+	GuardError TransitionErrorKind = TransitionErrorKind(internal.GuardTxError)
+	// InvalidStateError is a state the workflow does not declare.
+	InvalidStateError TransitionErrorKind = TransitionErrorKind(internal.InvalidStateTxError)
+	// UninitializedError is an entity whose state is unknown.
+	UninitializedError TransitionErrorKind = TransitionErrorKind(internal.UninitializedTxError)
+	// ForbiddenError is a change the workflow does not allow to be made, see ErrForbidden.
+	ForbiddenError TransitionErrorKind = "forbidden"
+	// ReasonRequiredError is an override without a reason, see ErrReasonRequired.
+	ReasonRequiredError TransitionErrorKind = "reason_required"
+	// UndoExpiredError is an approval undone too late, see ErrUndoExpired.
+	UndoExpiredError TransitionErrorKind = "undo_expired"
+	// NothingToUndoError is an undo of a change other than an approval, see ErrNothingToUndo.
+	NothingToUndoError TransitionErrorKind = "nothing_to_undo"
+	// OtherError is any other error.
+	OtherError TransitionErrorKind = "other"
+)
+
+// ErrUninitialized is wrapped by the errors of transitions attempted on an entity without a state
+// that its workflow does not start with. Such an entity is initialized with InitSm.
+var ErrUninitialized = internal.ErrUninitialized
+
+// ErrorKindOf returns the kind of an error returned by a transition method, or an empty kind if err is nil.
+func ErrorKindOf(err error) TransitionErrorKind {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrForbidden) {
+		return ForbiddenError
+	}
+	if errors.Is(err, ErrReasonRequired) {
+		return ReasonRequiredError
+	}
+	if errors.Is(err, ErrUndoExpired) {
+		return UndoExpiredError
+	}
+	if errors.Is(err, ErrNothingToUndo) {
+		return NothingToUndoError
+	}
+	if k := internal.TxErrorKindOf(err); k != "" {
+		return TransitionErrorKind(k)
+	}
+	return OtherError
+}
+
+// TransitionOutcome is a transition attempted on an entity.
+type TransitionOutcome struct {
+	Workflow   string
+	EntityType string
+	EntityID   string
+	// Actor is the actor given to SetActorSm.
+	Actor string
+
+	Kind  TransitionEventKind
+	Event types.TxEvent
+	From  types.TxState
+	// To and Version are the state and version of the entity after a successful transition.
+	To      types.TxState
+	Version uint64
+	// Reason is why the change was attempted, given to OverrideSm or AssignSm.
+	Reason string
+	// Assignee is who the entity is assigned to by a change of kind AssignedEventKind.
+	Assignee string
+
+	Result    TransitionResult
+	ErrorKind TransitionErrorKind
+	Err       error
+
+	Start   time.Time
+	Latency time.Duration
+}
+
+// TransitionObserver is called after every transition attempted on an entity, successful or not.
+// It must not transition the entity.
+type TransitionObserver interface {
+	ObserveTransition(o TransitionOutcome)
+}
+
+// TransitionObserverFunc adapts a function to TransitionObserver.
+type TransitionObserverFunc func(o TransitionOutcome)
+
+func (f TransitionObserverFunc) ObserveTransition(o TransitionOutcome) {
+	f(o)
+}
+
+// observe completes o, an outcome of sm embedded by host, with err and reports it to the observers of w.
+func (w *Workflow) observe(host, sm any, o TransitionOutcome, err error) {
+	if len(w.opts.observers) == 0 {
+		return
+	}
+	o.Latency = time.Since(o.Start)
+
+	o.Workflow = w.Name()
+	_, o.EntityType, o.EntityID = w.entityOf(host, sm)
+	o.Result = SucceededResult
+	if err != nil {
+		o.Result = FailedResult
+		o.ErrorKind = ErrorKindOf(err)
+		o.Err = err
+	}
+	for _, v := range w.opts.observers {
+		v.ObserveTransition(o)
+	}
+}
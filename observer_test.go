@@ -0,0 +1,49 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_TransitionObserver(t *testing.T) {
+	var got []TransitionOutcome
+	rejected := errors.New("rejected")
+	w, err := NewWorkflow("doc", "draft",
+		[]types.TxStateSpec{{Name: "draft"}, {Name: "published", Terminal: true}},
+		[]types.TxTransition{{From: "draft", Event: "publish", To: "published", Guard: "reviewed"}},
+		WithGuard("reviewed", func(t types.TxTransition) error { return rejected }),
+		WithObserver(TransitionObserverFunc(func(o TransitionOutcome) { got = append(got, o) })), WithUnrestrictedForce())
+	require.Nil(t, err)
+
+	e := &TxStateMachineClock{State: "draft"}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.NotNil(t, e.FireSm("publish"))
+	require.NotNil(t, e.FireSm("archive"))
+	require.Nil(t, e.ForceStateSm("published"))
+	e.State = "unknown"
+	require.NotNil(t, e.FireSm("publish"))
+
+	require.Len(t, got, 4)
+	require.Equal(t, FailedResult, got[0].Result)
+	require.Equal(t, GuardError, got[0].ErrorKind)
+	require.ErrorIs(t, got[0].Err, rejected)
+	require.Equal(t, types.TxState("draft"), got[0].From)
+	require.Equal(t, types.TxState(""), got[0].To)
+	require.Equal(t, "doc", got[0].Workflow)
+	require.Equal(t, "doc", got[0].EntityType)
+
+	require.Equal(t, NotPermittedError, got[1].ErrorKind)
+
+	require.Equal(t, SucceededResult, got[2].Result)
+	require.Equal(t, ForcedEventKind, got[2].Kind)
+	require.Equal(t, types.TxState("published"), got[2].To)
+	require.Equal(t, uint64(1), got[2].Version)
+	require.Nil(t, got[2].Err)
+	require.False(t, got[2].Start.IsZero())
+
+	require.Equal(t, InvalidStateError, got[3].ErrorKind)
+	require.Equal(t, types.TxState("unknown"), got[3].From)
+}
@@ -0,0 +1,33 @@
+package outbox
+
+import (
+	"gorm.io/gorm"
+)
+
+// GormWriter writes messages with GORM.
+type GormWriter struct {
+	opts options
+}
+
+func NewGormWriter(opts ...Option) *GormWriter {
+	return &GormWriter{opts: newOptions(opts)}
+}
+
+// Write inserts a message for every uncommitted event of host using tx,
+// which should be the transaction host is saved in.
+func (w *GormWriter) Write(tx *gorm.DB, host Entity) error {
+	msgs, err := Messages(host)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return tx.Table(w.opts.table).Create(&msgs).Error
+}
+
+// AutoMigrate creates or updates the table of messages.
+func AutoMigrate(db *gorm.DB, opts ...Option) error {
+	o := newOptions(opts)
+	return db.Table(o.table).AutoMigrate(&Message{})
+}
@@ -0,0 +1,38 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher keeps published messages in memory. It is meant for tests.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+
+	// Fail, if set, is called before a message is kept. A non-nil error fails the publication.
+	Fail func(m Message) error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, m Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Fail != nil {
+		if err := p.Fail(m); err != nil {
+			return err
+		}
+	}
+	p.messages = append(p.messages, m)
+	return nil
+}
+
+// Messages returns the published messages in the order they were published.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
@@ -0,0 +1,98 @@
+// Package outbox stores transitions of entities in the same database transaction as the entities,
+// and relays them to a Publisher afterwards.
+//
+// Entities must follow a workflow built with state.WithEventSourcing, so that their transitions
+// are kept in their uncommitted events, must implement state.Identifier and embed state.EventSequence.
+// Writing any other entity fails.
+//
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		if err := tx.Save(order).Error; err != nil {
+//			return err
+//		}
+//		return outbox.NewGormWriter().Write(tx, order)
+//	})
+//	if err == nil {
+//		order.ClearEventsSm()
+//	}
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+)
+
+// DefaultTable is the table messages are stored in.
+const DefaultTable = "state_outbox"
+
+// Message is a transition waiting to be published.
+type Message struct {
+	ID         int64         `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EntityType string        `gorm:"column:entity_type;size:64;not null;uniqueIndex:idx_state_outbox_entity,priority:1" json:"entity_type"`
+	EntityID   string        `gorm:"column:entity_id;size:64;not null;uniqueIndex:idx_state_outbox_entity,priority:2" json:"entity_id"`
+	Sequence   uint64        `gorm:"column:sequence;not null;uniqueIndex:idx_state_outbox_entity,priority:3" json:"sequence"`
+	Kind       string        `gorm:"column:kind;size:32;not null" json:"kind"`
+	Event      types.TxEvent `gorm:"column:event;type:string;size:32" json:"event,omitempty"`
+	From       types.TxState `gorm:"column:from_state;type:string;size:32" json:"from"`
+	To         types.TxState `gorm:"column:to_state;type:string;size:32;not null" json:"to"`
+	Reason     string        `gorm:"column:reason;size:256" json:"reason,omitempty"`
+	Assignee   string        `gorm:"column:assignee;size:64" json:"assignee,omitempty"`
+	Version    uint64        `gorm:"column:version;not null" json:"version"`
+	OccurredAt time.Time     `gorm:"column:occurred_at;not null" json:"occurred_at"`
+
+	PublishedAt *time.Time `gorm:"column:published_at;index:idx_state_outbox_published_at" json:"published_at,omitempty"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"column:last_error;size:1024" json:"last_error,omitempty"`
+}
+
+func (Message) TableName() string {
+	return DefaultTable
+}
+
+// Entity is a host of state.TxStateMachineClock that can be written to the outbox.
+// It embeds state.EventSequence and is given to SetHostSm, so that the sequence of its events
+// goes on from where it was when it is loaded again.
+type Entity interface {
+	state.Identifier
+	state.Sequenced
+	UncommittedEventsSm() []state.TransitionEvent
+	WorkflowSm() *state.Workflow
+}
+
+// Messages returns a message for every uncommitted event of host.
+// It fails if the workflow of host does not use state.WithEventSourcing, which would leave no events to write.
+func Messages(host Entity) ([]Message, error) {
+	id := host.EntityID()
+	if id == "" {
+		return nil, errors.New("entity id is empty")
+	}
+	if w := host.WorkflowSm(); !w.EventSourcing() {
+		return nil, fmt.Errorf("workflow %s does not use event sourcing", w.Name())
+	}
+	entityType := state.EntityTypeOf(host)
+
+	events := host.UncommittedEventsSm()
+	if n := len(events); n > 0 && events[n-1].Sequence != host.SequenceSm() {
+		return nil, errors.New("sequence of events is not kept by the entity, see state.EventSequence and SetHostSm")
+	}
+	res := make([]Message, 0, len(events))
+	for _, ev := range events {
+		res = append(res, Message{
+			EntityType: entityType,
+			EntityID:   id,
+			Sequence:   ev.Sequence,
+			Kind:       string(ev.Kind),
+			Event:      ev.Event,
+			From:       ev.From,
+			To:         ev.To,
+			Reason:     ev.Reason,
+			Assignee:   ev.Assignee,
+			Version:    ev.Version,
+			OccurredAt: ev.OccurredAt,
+		})
+	}
+	return res, nil
+}
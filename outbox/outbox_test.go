@@ -0,0 +1,242 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type order struct {
+	ID string `gorm:"primaryKey"`
+	state.TxStateMachineClock
+	state.Assignment
+	state.EventSequence
+}
+
+func (o *order) EntityID() string {
+	return o.ID
+}
+
+func newOrder(t *testing.T, id string) *order {
+	w, err := state.DefaultWorkflow().With(state.WithEventSourcing())
+	require.Nil(t, err)
+	o := &order{ID: id}
+	require.Nil(t, o.SetWorkflowSm(w))
+	o.SetHostSm(o)
+	return o
+}
+
+func openGorm(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	require.Nil(t, err)
+	sqlDB, err := db.DB()
+	require.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.Nil(t, db.AutoMigrate(&order{}))
+	require.Nil(t, AutoMigrate(db))
+	return db
+}
+
+func Test_Messages(t *testing.T) {
+	o := newOrder(t, "")
+	require.Nil(t, o.PendingSm())
+	_, err := Messages(o)
+	require.NotNil(t, err)
+
+	o.ID = "o-1"
+	o.ResetTicked()
+	require.Nil(t, o.ApproveSm())
+	msgs, err := Messages(o)
+	require.Nil(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "order", msgs[0].EntityType)
+	require.Equal(t, "o-1", msgs[1].EntityID)
+	require.Equal(t, uint64(2), msgs[1].Sequence)
+	require.Equal(t, types.ApproveTxEvent, msgs[1].Event)
+	require.Equal(t, types.PendingTxState, msgs[1].From)
+	require.Equal(t, types.ActiveTxState, msgs[1].To)
+	require.Equal(t, uint64(2), msgs[1].Version)
+
+	// without event sourcing there would be nothing to write
+	plain := &order{ID: "o-2"}
+	require.Nil(t, plain.InitSm(""))
+	_, err = Messages(plain)
+	require.ErrorContains(t, err, "does not use event sourcing")
+	require.NotNil(t, NewGormWriter().Write(nil, plain))
+}
+
+func Test_GormWriter_rolls_back_with_entity(t *testing.T) {
+	db := openGorm(t)
+	w := NewGormWriter()
+
+	o := newOrder(t, "o-1")
+	require.Nil(t, o.PendingSm())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.Nil(t, tx.Create(o).Error)
+		require.Nil(t, w.Write(tx, o))
+		return errors.New("rollback")
+	})
+	require.NotNil(t, err)
+
+	var n int64
+	require.Nil(t, db.Model(&Message{}).Count(&n).Error)
+	require.Equal(t, int64(0), n)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(o).Error; err != nil {
+			return err
+		}
+		return w.Write(tx, o)
+	})
+	require.Nil(t, err)
+	o.ClearEventsSm()
+
+	require.Nil(t, db.Model(&Message{}).Count(&n).Error)
+	require.Equal(t, int64(1), n)
+
+	// nothing to write once the events are cleared
+	require.Nil(t, w.Write(db, o))
+	require.Nil(t, db.Model(&Message{}).Count(&n).Error)
+	require.Equal(t, int64(1), n)
+}
+
+func Test_GormWriter_after_reload(t *testing.T) {
+	db := openGorm(t)
+	w := NewGormWriter()
+	save := func(o *order) {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(o).Error; err != nil {
+				return err
+			}
+			return w.Write(tx, o)
+		})
+		require.Nil(t, err)
+		o.ClearEventsSm()
+	}
+
+	o := newOrder(t, "o-1")
+	require.Nil(t, o.PendingSm())
+	save(o)
+
+	// the order loaded again numbers its events after those in the outbox
+	loaded := &order{}
+	require.Nil(t, db.First(loaded, "id = ?", "o-1").Error)
+	require.Equal(t, uint64(1), loaded.Sequence)
+	require.Nil(t, loaded.SetWorkflowSm(o.WorkflowSm()))
+	loaded.SetHostSm(loaded)
+	require.Nil(t, loaded.ApproveSm())
+	save(loaded)
+
+	var msgs []Message
+	require.Nil(t, db.Order("id").Find(&msgs).Error)
+	require.Len(t, msgs, 2)
+	require.Equal(t, uint64(1), msgs[0].Sequence)
+	require.Equal(t, uint64(2), msgs[1].Sequence)
+	require.Equal(t, types.ActiveTxState, msgs[1].To)
+
+	// a host that does not keep the sequence would repeat it
+	plain := &order{ID: "o-1", TxStateMachineClock: state.TxStateMachineClock{State: types.ActiveTxState}}
+	require.Nil(t, plain.SetWorkflowSm(o.WorkflowSm()))
+	require.Nil(t, plain.ModifyPendingSm())
+	require.ErrorContains(t, w.Write(db, plain), "EventSequence")
+}
+
+func Test_Relay(t *testing.T) {
+	db := openGorm(t)
+	sqlDB, err := db.DB()
+	require.Nil(t, err)
+	ctx := context.Background()
+
+	writer := NewSQLWriter()
+	save := func(o *order) {
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		require.Nil(t, err)
+		require.Nil(t, writer.Write(ctx, tx, o))
+		require.Nil(t, tx.Commit())
+		o.ClearEventsSm()
+	}
+
+	a := newOrder(t, "a")
+	b := newOrder(t, "b")
+	require.Nil(t, a.PendingSm())
+	a.ResetTicked()
+	require.Nil(t, a.ApproveSm())
+	save(a)
+	require.Nil(t, b.PendingSm())
+	require.Nil(t, b.AssignSm("alice", "on duty"))
+	save(b)
+
+	// the first event of a fails, so its second event waits
+	pub := NewMemoryPublisher()
+	failed := false
+	pub.Fail = func(m Message) error {
+		if m.EntityID == "a" && !failed {
+			failed = true
+			return errors.New("broker is down")
+		}
+		return nil
+	}
+	store := NewSQLStore(sqlDB)
+	relay := NewRelay(store, pub, WithBatchSize(10))
+
+	n, err := relay.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, pub.Messages(), 2)
+	require.Equal(t, "b", pub.Messages()[0].EntityID)
+	require.Equal(t, "alice", pub.Messages()[1].Assignee)
+	require.Equal(t, "on duty", pub.Messages()[1].Reason)
+
+	pending, err := store.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, 1, pending[0].Attempts)
+	require.Equal(t, "broker is down", pending[0].LastError)
+
+	n, err = relay.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	msgs := pub.Messages()
+	require.Len(t, msgs, 4)
+	require.Equal(t, uint64(1), msgs[2].Sequence)
+	require.Equal(t, uint64(2), msgs[3].Sequence)
+	require.Equal(t, types.ActiveTxState, msgs[3].To)
+
+	pending, err = store.Pending(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, pending, 0)
+
+	var m Message
+	require.Nil(t, db.First(&m, msgs[3].ID).Error)
+	require.NotNil(t, m.PublishedAt)
+}
+
+func Test_Relay_Run_stops(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE events (id INTEGER PRIMARY KEY AUTOINCREMENT, entity_type TEXT, entity_id TEXT, sequence INTEGER, kind TEXT, event TEXT, from_state TEXT, to_state TEXT, reason TEXT, assignee TEXT, version INTEGER, occurred_at DATETIME, published_at DATETIME, attempts INTEGER, last_error TEXT)`)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pub := PublisherFunc(func(ctx context.Context, m Message) error {
+		cancel()
+		return nil
+	})
+	o := newOrder(t, "o-1")
+	require.Nil(t, o.PendingSm())
+	require.Nil(t, NewSQLWriter(WithTable("events")).Write(ctx, db, o))
+
+	err = NewRelay(NewSQLStore(db, WithTable("events")), pub).Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Publisher sends a message to other services.
+// A message may be published more than once, so consumers should deduplicate by
+// EntityType, EntityID and Sequence.
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+// PublisherFunc adapts a function to Publisher.
+type PublisherFunc func(ctx context.Context, m Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// Relay publishes stored messages and marks them published.
+//
+// A message is marked only after it is published, so it is delivered at least once.
+// Messages of an entity are published in the order they were written: once one fails,
+// the later messages of the entity wait for the next run.
+// Run a single Relay per table.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	now       func() time.Time
+}
+
+// RelayOption configures a Relay.
+type RelayOption func(r *Relay)
+
+// WithBatchSize sets how many messages a run reads at most. The default is 100.
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithInterval sets how long Run waits between runs. The default is a second.
+func WithInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+func NewRelay(store Store, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		store:     store,
+		publisher: publisher,
+		batchSize: 100,
+		interval:  time.Second,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RunOnce publishes a batch of pending messages and returns how many were published.
+// Failed publications are recorded on their messages and do not make RunOnce fail.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	msgs, err := r.store.Pending(ctx, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type entity struct{ entityType, id string }
+	blocked := make(map[entity]bool)
+	published := 0
+	for _, m := range msgs {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		key := entity{m.EntityType, m.EntityID}
+		if blocked[key] {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, m); err != nil {
+			blocked[key] = true
+			if err := r.store.MarkFailed(ctx, m.ID, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.store.MarkPublished(ctx, m.ID, r.now()); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// Run calls RunOnce until ctx is done. A full batch is followed by another run without waiting.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RunOnce(ctx)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		if err == nil && n >= r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
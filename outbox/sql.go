@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wonksing/state/types"
+)
+
+// Placeholder returns the bind parameter of the n-th argument of a query, starting at 1.
+type Placeholder func(n int) string
+
+// QuestionPlaceholder is used by MySQL and SQLite.
+func QuestionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder is used by PostgreSQL.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+type options struct {
+	table       string
+	placeholder Placeholder
+}
+
+// Option configures writers and stores.
+type Option func(o *options)
+
+// WithTable stores messages in table instead of DefaultTable.
+func WithTable(table string) Option {
+	return func(o *options) {
+		o.table = table
+	}
+}
+
+// WithPlaceholder sets the bind parameters of queries. The default is QuestionPlaceholder.
+func WithPlaceholder(p Placeholder) Option {
+	return func(o *options) {
+		o.placeholder = p
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{table: DefaultTable, placeholder: QuestionPlaceholder}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) bind(from, n int) string {
+	res := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, o.placeholder(from+i))
+	}
+	return strings.Join(res, ", ")
+}
+
+// Execer is implemented by *sql.Tx, *sql.DB and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// SQLWriter writes messages with database/sql.
+type SQLWriter struct {
+	opts options
+}
+
+func NewSQLWriter(opts ...Option) *SQLWriter {
+	return &SQLWriter{opts: newOptions(opts)}
+}
+
+// Write inserts a message for every uncommitted event of host using tx,
+// which should be the transaction host is saved in.
+func (w *SQLWriter) Write(ctx context.Context, tx Execer, host Entity) error {
+	msgs, err := Messages(host)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (entity_type, entity_id, sequence, kind, event, from_state, to_state, reason, assignee, version, occurred_at, attempts) VALUES (%s)",
+		w.opts.table, w.opts.bind(1, 12))
+	for _, m := range msgs {
+		_, err := tx.ExecContext(ctx, query,
+			m.EntityType, m.EntityID, m.Sequence, m.Kind, string(m.Event), string(m.From), string(m.To), m.Reason, m.Assignee, m.Version, m.OccurredAt, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store reads and updates messages for a Relay.
+type Store interface {
+	// Pending returns up to limit unpublished messages ordered by ID.
+	Pending(ctx context.Context, limit int) ([]Message, error)
+	MarkPublished(ctx context.Context, id int64, at time.Time) error
+	MarkFailed(ctx context.Context, id int64, cause error) error
+}
+
+// SQLStore is a Store using database/sql.
+type SQLStore struct {
+	db   *sql.DB
+	opts options
+}
+
+func NewSQLStore(db *sql.DB, opts ...Option) *SQLStore {
+	return &SQLStore{db: db, opts: newOptions(opts)}
+}
+
+func (s *SQLStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	query := fmt.Sprintf("SELECT id, entity_type, entity_id, sequence, kind, event, from_state, to_state, reason, assignee, version, occurred_at, attempts, last_error FROM %s WHERE published_at IS NULL ORDER BY id LIMIT %s",
+		s.opts.table, s.opts.placeholder(1))
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Message
+	for rows.Next() {
+		var m Message
+		var event, reason, assignee, lastError sql.NullString
+		err := rows.Scan(&m.ID, &m.EntityType, &m.EntityID, &m.Sequence, &m.Kind, &event, &m.From, &m.To, &reason, &assignee, &m.Version, &m.OccurredAt, &m.Attempts, &lastError)
+		if err != nil {
+			return nil, err
+		}
+		m.Event = types.TxEvent(event.String)
+		m.Reason, m.Assignee = reason.String, assignee.String
+		m.LastError = lastError.String
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+func (s *SQLStore) MarkPublished(ctx context.Context, id int64, at time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET published_at = %s, attempts = attempts + 1, last_error = NULL WHERE id = %s",
+		s.opts.table, s.opts.placeholder(1), s.opts.placeholder(2))
+	return s.exec(ctx, query, at, id)
+}
+
+func (s *SQLStore) MarkFailed(ctx context.Context, id int64, cause error) error {
+	msg := ""
+	if cause != nil {
+		msg = cause.Error()
+	}
+	if len(msg) > 1024 {
+		msg = msg[:1024]
+	}
+	query := fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, last_error = %s WHERE id = %s",
+		s.opts.table, s.opts.placeholder(1), s.opts.placeholder(2))
+	return s.exec(ctx, query, msg, id)
+}
+
+func (s *SQLStore) exec(ctx context.Context, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("message not found")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wonksing/state/types"
+)
+
+type SLAStatus string
+
+const (
+	SLAOK       SLAStatus = "ok"
+	SLAWarning  SLAStatus = "warning"
+	SLABreached SLAStatus = "breached"
+)
+
+// severity orders statuses from the least to the most severe.
+func (s SLAStatus) severity() int {
+	switch s {
+	case SLAWarning:
+		return 1
+	case SLABreached:
+		return 2
+	}
+	return 0
+}
+
+// SLARule limits how long an entity may stay in State.
+// It is a warning after Warning, if it is not zero, and breached after Breach.
+type SLARule struct {
+	State   types.TxState
+	Warning time.Duration
+	Breach  time.Duration
+}
+
+// WithSLA sets rules on states of the workflow. A later rule for the same state replaces the earlier one.
+func WithSLA(rules ...SLARule) WorkflowOption {
+	return func(o *workflowOptions) {
+		if o.sla == nil {
+			o.sla = make(map[types.TxState]SLARule)
+		}
+		for _, r := range rules {
+			o.sla[r.State] = r
+		}
+	}
+}
+
+// SLARule returns the rule of s.
+func (w *Workflow) SLARule(s types.TxState) (SLARule, bool) {
+	r, ok := w.opts.sla[s]
+	return r, ok
+}
+
+// SLAReport is how long an entity has been in its state against the rule of the state.
+// Elapsed and Overdue count the working time of the calendar of the workflow.
+type SLAReport struct {
+	State   types.TxState
+	Status  SLAStatus
+	Rule    SLARule
+	Elapsed time.Duration
+	// Overdue is how long ago the SLA was breached, or how long is left if it is negative.
+	Overdue time.Duration
+}
+
+// TimeInCurrentStateSm returns how long e has been in its state at now, including time off the calendar.
+// It counts from the UpdatedAt of its clock if the time e entered its state is unknown, and is zero if that is unknown too.
+func (e *core[C, PC]) TimeInCurrentStateSm(now time.Time) time.Duration {
+	if e == nil {
+		return 0
+	}
+	at, ok := e.enteredAt()
+	if !ok {
+		return 0
+	}
+	return now.Sub(at)
+}
+
+// SLAStatusSm returns the status of e against the rule of its state at now.
+// It is SLAOK if the state has no rule.
+func (e *core[C, PC]) SLAStatusSm(now time.Time) SLAStatus {
+	return e.SLAReportSm(now).Status
+}
+
+// SLAReportSm reports e against the rule of its state at now.
+func (e *core[C, PC]) SLAReportSm(now time.Time) SLAReport {
+	if e == nil {
+		return SLAReport{Status: SLAOK}
+	}
+	res := SLAReport{State: *e.state, Status: SLAOK}
+	w := e.WorkflowSm()
+	r, ok := w.SLARule(*e.state)
+	if !ok {
+		return res
+	}
+	if at, ok := e.enteredAt(); ok {
+		res.Elapsed = w.Calendar().Elapsed(at, now)
+	}
+	res.Rule = r
+	res.Overdue = res.Elapsed - r.Breach
+	switch {
+	case res.Elapsed >= r.Breach:
+		res.Status = SLABreached
+	case r.Warning > 0 && res.Elapsed >= r.Warning:
+		res.Status = SLAWarning
+	}
+	return res
+}
+
+// SLADeadlineSm returns when e breaches the rule of its state, counting the working time of the calendar of its workflow.
+// It returns false if the state has no rule or neither the time e entered it nor UpdatedAt is known.
+func (e *core[C, PC]) SLADeadlineSm() (time.Time, bool) {
+	if e == nil {
+		return time.Time{}, false
+	}
+	w := e.WorkflowSm()
+	r, ok := w.SLARule(*e.state)
+	if !ok {
+		return time.Time{}, false
+	}
+	at, ok := e.enteredAt()
+	if !ok {
+		return time.Time{}, false
+	}
+	return w.Calendar().Add(at, r.Breach), true
+}
+
+// SLASubject is an entity whose SLA can be evaluated, e.g. a host of TxStateMachineClock.
+type SLASubject interface {
+	SLAReportSm(now time.Time) SLAReport
+}
+
+// SLAViolation is an entity in a warning or breached state.
+type SLAViolation struct {
+	Entity     any
+	EntityType string
+	EntityID   string
+	SLAReport
+}
+
+// EvaluateSLA returns the entities in a warning or breached state at now, the most severe first.
+// Breaches come before warnings, and the longest overdue first within each.
+// Ties keep the order of entities.
+func EvaluateSLA[T SLASubject](entities []T, now time.Time) []SLAViolation {
+	var res []SLAViolation
+	for _, v := range entities {
+		r := v.SLAReportSm(now)
+		if r.Status == SLAOK {
+			continue
+		}
+		res = append(res, SLAViolation{
+			Entity:     v,
+			EntityType: EntityTypeOf(v),
+			EntityID:   EntityIDOf(v),
+			SLAReport:  r,
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Status != b.Status {
+			return a.Status.severity() > b.Status.severity()
+		}
+		return a.Overdue > b.Overdue
+	})
+	return res
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+type slaRequest struct {
+	ID string
+	TxStateMachineClock
+	Lifecycle
+}
+
+func (r *slaRequest) EntityID() string {
+	return r.ID
+}
+
+func Test_SLA(t *testing.T) {
+	c := NewManualClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	w, err := DefaultWorkflow().With(WithClock(c), WithSLA(
+		SLARule{State: types.PendingTxState, Warning: 24 * time.Hour, Breach: 48 * time.Hour},
+		SLARule{State: types.ModifyPendingTxState, Warning: 36 * time.Hour, Breach: 48 * time.Hour},
+	))
+	require.Nil(t, err)
+
+	_, err = DefaultWorkflow().With(WithSLA(SLARule{State: "unknown", Breach: time.Hour}))
+	require.NotNil(t, err)
+	_, err = DefaultWorkflow().With(WithSLA(SLARule{State: types.PendingTxState, Warning: time.Hour, Breach: time.Hour}))
+	require.NotNil(t, err)
+
+	newRequest := func(id string) *slaRequest {
+		r := &slaRequest{ID: id}
+		require.Nil(t, r.SetWorkflowSm(w))
+		r.SetHostSm(r)
+		require.Nil(t, r.PendingSm())
+		return r
+	}
+
+	a := newRequest("a")
+	c.Advance(10 * time.Hour)
+	b := newRequest("b")
+	c.Advance(10 * time.Hour)
+	d := newRequest("d")
+	d.ResetTicked()
+	require.Nil(t, d.ApproveSm())
+	e := newRequest("e")
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.Nil(t, e.ModifyPendingSm())
+
+	now := c.Now()
+	require.Equal(t, 20*time.Hour, a.TimeInCurrentStateSm(now))
+	require.Equal(t, time.Duration(0), (&TxStateMachineClock{}).TimeInCurrentStateSm(now))
+	require.Equal(t, SLAOK, a.SLAStatusSm(now))
+	require.Equal(t, SLAWarning, a.SLAStatusSm(now.Add(4*time.Hour)))
+	require.Equal(t, SLABreached, a.SLAStatusSm(now.Add(28*time.Hour)))
+	require.Equal(t, SLAOK, d.SLAStatusSm(now.Add(100*time.Hour)))
+
+	violations := EvaluateSLA([]*slaRequest{a, b, d, e}, now.Add(40*time.Hour))
+	require.Len(t, violations, 3)
+	require.Equal(t, "a", violations[0].EntityID)
+	require.Equal(t, SLABreached, violations[0].Status)
+	require.Equal(t, 12*time.Hour, violations[0].Overdue)
+	require.Equal(t, "b", violations[1].EntityID)
+	require.Equal(t, SLABreached, violations[1].Status)
+	require.Equal(t, "e", violations[2].EntityID)
+	require.Equal(t, SLAWarning, violations[2].Status)
+	require.Equal(t, "slaRequest", violations[2].EntityType)
+}
+
+func Test_SLA_without_entered_at(t *testing.T) {
+	w, err := DefaultWorkflow().With(WithSLA(SLARule{State: types.PendingTxState, Breach: 48 * time.Hour}))
+	require.Nil(t, err)
+
+	// a row saved before EnteredAt was recorded
+	updated := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := &slaRequest{ID: "legacy", TxStateMachineClock: TxStateMachineClock{State: types.PendingTxState, TxClock: TxClock{Version: 3, UpdatedAt: &updated}}}
+	require.Nil(t, r.SetWorkflowSm(w))
+
+	now := updated.Add(72 * time.Hour)
+	require.Equal(t, 72*time.Hour, r.TimeInCurrentStateSm(now))
+	require.Equal(t, SLABreached, r.SLAStatusSm(now))
+	deadline, ok := r.SLADeadlineSm()
+	require.True(t, ok)
+	require.Equal(t, updated.Add(48*time.Hour), deadline)
+
+	violations := EvaluateSLA([]*slaRequest{r}, now)
+	require.Len(t, violations, 1)
+	require.Equal(t, 24*time.Hour, violations[0].Overdue)
+}
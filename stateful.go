@@ -0,0 +1,562 @@
+package state
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// Ticker is a clock that Stateful ticks on every transition, e.g. TxClock, TxHybridClock or NoClock.
+type Ticker interface {
+	Tick()
+	ResetTicked()
+}
+
+// NoClock is a Ticker that does nothing, for a Stateful that keeps no clock like TxStateMachine.
+type NoClock struct{}
+
+func (NoClock) Tick()        {}
+func (NoClock) ResetTicked() {}
+
+// versioned is a Ticker counting its ticks, which become the version of transitions.
+type versioned interface {
+	version() uint64
+}
+
+// timed is a Ticker keeping when it was created and last ticked, like TxClock and TxHybridClock.
+type timed interface {
+	// tickAt ticks at now instead of the time of DefaultClock.
+	tickAt(now time.Time)
+	times() (createdAt, updatedAt *time.Time)
+	// restore sets the clock as it was saved, without ticking.
+	restore(version uint64, createdAt, updatedAt *time.Time)
+}
+
+// Stateful is a state machine with a clock of type C, ticked through PC after every transition.
+// A host may embed any combination, e.g. Stateful[TxHybridClock, *TxHybridClock].
+// The columns of Clock are embedded in the table of the entity, but Clock is nested in JSON and Go,
+// since a type parameter cannot be embedded. TxStateMachine and TxStateMachineClock embed their clock instead.
+type Stateful[C any, PC interface {
+	*C
+	Ticker
+}] struct {
+	State types.TxState `gorm:"column:state;type:string;size:32;comment:state" json:"state,omitempty"`
+	Clock C             `gorm:"embedded" json:"clock"`
+	machine
+}
+
+func (e *Stateful[C, PC]) sm() *core[C, PC] {
+	if e == nil {
+		return nil
+	}
+	return &core[C, PC]{machine: &e.machine, state: &e.State, clock: &e.Clock, self: e}
+}
+
+// AssignStateCallback sets newState to underlying State. It implements internal.TxStateAssignor interface.
+// DO NOT CALL THIS METHOD DIRECTLY.
+func (e *Stateful[C, PC]) AssignStateCallback(newState types.TxState) error {
+	e.State = newState
+	return nil
+}
+
+// The methods below run those of core, where they are documented, on State and Clock.
+
+func (e *Stateful[C, PC]) InitSm(s types.TxState) error {
+	return e.sm().InitSm(s)
+}
+
+func (e *Stateful[C, PC]) ForceStateSm(newState types.TxState) error {
+	return e.sm().ForceStateSm(newState)
+}
+
+func (e *Stateful[C, PC]) OverrideSm(r Override) error {
+	return e.sm().OverrideSm(r)
+}
+
+func (e *Stateful[C, PC]) PendingSm() error {
+	return e.sm().PendingSm()
+}
+
+func (e *Stateful[C, PC]) ModifyPendingSm() error {
+	return e.sm().ModifyPendingSm()
+}
+
+func (e *Stateful[C, PC]) RemovePendingSm() error {
+	return e.sm().RemovePendingSm()
+}
+
+func (e *Stateful[C, PC]) InactivePendingSm() error {
+	return e.sm().InactivePendingSm()
+}
+
+func (e *Stateful[C, PC]) ActivePendingSm() error {
+	return e.sm().ActivePendingSm()
+}
+
+func (e *Stateful[C, PC]) ApproveSm() error {
+	return e.sm().ApproveSm()
+}
+
+func (e *Stateful[C, PC]) CancelSm() error {
+	return e.sm().CancelSm()
+}
+
+func (e *Stateful[C, PC]) FireSm(ev types.TxEvent) error {
+	return e.sm().FireSm(ev)
+}
+
+func (e *Stateful[C, PC]) UndoSm() error {
+	return e.sm().UndoSm()
+}
+
+func (e *Stateful[C, PC]) AssignSm(assignee, reason string) error {
+	return e.sm().AssignSm(assignee, reason)
+}
+
+func (e *Stateful[C, PC]) ImportSm(r Import) error {
+	return e.sm().ImportSm(r)
+}
+
+func (e *Stateful[C, PC]) EqualSm(s types.TxState) bool {
+	return e.sm().EqualSm(s)
+}
+
+func (e *Stateful[C, PC]) IsPendingKindSm() bool {
+	return e.sm().IsPendingKindSm()
+}
+
+func (e *Stateful[C, PC]) IsPendingSm() bool {
+	return e.sm().IsPendingSm()
+}
+
+func (e *Stateful[C, PC]) IsModifyPendingSm() bool {
+	return e.sm().IsModifyPendingSm()
+}
+
+func (e *Stateful[C, PC]) IsRemovePendingSm() bool {
+	return e.sm().IsRemovePendingSm()
+}
+
+func (e *Stateful[C, PC]) IsActiveSm() bool {
+	return e.sm().IsActiveSm()
+}
+
+func (e *Stateful[C, PC]) IsCanceledSm() bool {
+	return e.sm().IsCanceledSm()
+}
+
+func (e *Stateful[C, PC]) IsRemovedSm() bool {
+	return e.sm().IsRemovedSm()
+}
+
+func (e *Stateful[C, PC]) IsCategorySm(c types.TxCategory) bool {
+	return e.sm().IsCategorySm(c)
+}
+
+func (e *Stateful[C, PC]) SetHostSm(host any) {
+	e.sm().SetHostSm(host)
+}
+
+func (e *Stateful[C, PC]) SetActorSm(actor string) {
+	e.sm().SetActorSm(actor)
+}
+
+func (e *Stateful[C, PC]) ActorSm() string {
+	return e.sm().ActorSm()
+}
+
+func (e *Stateful[C, PC]) SetWorkflowSm(w *Workflow) error {
+	return e.sm().SetWorkflowSm(w)
+}
+
+func (e *Stateful[C, PC]) WorkflowSm() *Workflow {
+	return e.sm().WorkflowSm()
+}
+
+func (e *Stateful[C, PC]) Tick() {
+	e.sm().Tick()
+}
+
+func (e *Stateful[C, PC]) ResetTicked() {
+	e.sm().ResetTicked()
+}
+
+func (e *Stateful[C, PC]) BeginUnit() {
+	e.sm().BeginUnit()
+}
+
+func (e *Stateful[C, PC]) CommitUnit() {
+	e.sm().CommitUnit()
+}
+
+func (e *Stateful[C, PC]) RollbackUnit() {
+	e.sm().RollbackUnit()
+}
+
+func (e *Stateful[C, PC]) UncommittedEventsSm() []TransitionEvent {
+	return e.sm().UncommittedEventsSm()
+}
+
+func (e *Stateful[C, PC]) ClearEventsSm() {
+	e.sm().ClearEventsSm()
+}
+
+func (e *Stateful[C, PC]) SnapshotSm() Snapshot {
+	return e.sm().SnapshotSm()
+}
+
+func (e *Stateful[C, PC]) ReplaySm(snapshot *Snapshot, events []TransitionEvent) error {
+	return e.sm().ReplaySm(snapshot, events)
+}
+
+func (e *Stateful[C, PC]) EscalateSm(now time.Time, ds Delegations) (Escalation, bool, error) {
+	return e.sm().EscalateSm(now, ds)
+}
+
+func (e *Stateful[C, PC]) TimeInCurrentStateSm(now time.Time) time.Duration {
+	return e.sm().TimeInCurrentStateSm(now)
+}
+
+func (e *Stateful[C, PC]) SLAStatusSm(now time.Time) SLAStatus {
+	return e.sm().SLAStatusSm(now)
+}
+
+func (e *Stateful[C, PC]) SLAReportSm(now time.Time) SLAReport {
+	return e.sm().SLAReportSm(now)
+}
+
+func (e *Stateful[C, PC]) SLADeadlineSm() (time.Time, bool) {
+	return e.sm().SLADeadlineSm()
+}
+
+// core implements the methods of Stateful, TxStateMachine and TxStateMachineClock,
+// which keep their State and clock where they are persisted and give core pointers to them.
+type core[C any, PC interface {
+	*C
+	Ticker
+}] struct {
+	*machine
+	state *types.TxState
+	clock PC
+	// self is the entity embedding the state machine, which assigns its state.
+	self internal.TxStateAssignor
+}
+
+func (e *core[C, PC]) EqualSm(s types.TxState) bool {
+	return e.query(equal(s))
+}
+
+func (e *core[C, PC]) IsPendingKindSm() bool {
+	return e.query((*internal.TxStateMachine).IsPendingKind)
+}
+
+func (e *core[C, PC]) IsPendingSm() bool {
+	return e.query((*internal.TxStateMachine).IsPending)
+}
+
+func (e *core[C, PC]) IsModifyPendingSm() bool {
+	return e.query((*internal.TxStateMachine).IsModifyPending)
+}
+
+func (e *core[C, PC]) IsRemovePendingSm() bool {
+	return e.query((*internal.TxStateMachine).IsRemovePending)
+}
+
+func (e *core[C, PC]) IsActiveSm() bool {
+	return e.query((*internal.TxStateMachine).IsActive)
+}
+
+func (e *core[C, PC]) IsCanceledSm() bool {
+	return e.query((*internal.TxStateMachine).IsCanceled)
+}
+
+func (e *core[C, PC]) IsRemovedSm() bool {
+	return e.query((*internal.TxStateMachine).IsRemoved)
+}
+
+// InitSm gives e its first state, s or the initial state of its workflow if s is empty.
+// It fails if e already has a state. Queries like IsActiveSm never initialize e.
+func (e *core[C, PC]) InitSm(s types.TxState) error {
+	return e.transition(InitializedEventKind, "", initState(s))
+}
+
+// ForceStateSm forces e to newState without a reason. It fails with ErrForbidden unless
+// its workflow uses WithUnrestrictedForce; see OverrideSm otherwise.
+func (e *core[C, PC]) ForceStateSm(newState types.TxState) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.transition(ForcedEventKind, "", e.forceState(newState))
+}
+
+// OverrideSm forces e to r.To regardless of the transitions of its workflow, if its ForcePolicy allows r.
+// It fails with ErrForbidden if the workflow has no ForcePolicy.
+// r.Reason is required, see ErrReasonRequired, and recorded with the change.
+func (e *core[C, PC]) OverrideSm(r Override) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.change(TransitionOutcome{Kind: OverriddenEventKind, Reason: r.Reason}, e.override(e.self, r))
+}
+
+func (e *core[C, PC]) PendingSm() error {
+	return e.transition(TransitionedEventKind, types.PendingTxEvent, setState(types.PendingTxState))
+}
+
+func (e *core[C, PC]) ModifyPendingSm() error {
+	return e.transition(TransitionedEventKind, types.ModifyPendingTxEvent, setState(types.ModifyPendingTxState))
+}
+
+func (e *core[C, PC]) RemovePendingSm() error {
+	return e.transition(TransitionedEventKind, types.RemovePendingTxEvent, setState(types.RemovePendingTxState))
+}
+
+func (e *core[C, PC]) InactivePendingSm() error {
+	return e.transition(TransitionedEventKind, types.InactivePendingTxEvent, setState(types.InactivePendingTxState))
+}
+
+func (e *core[C, PC]) ActivePendingSm() error {
+	return e.transition(TransitionedEventKind, types.ActivePendingTxEvent, setState(types.ActivePendingTxState))
+}
+
+func (e *core[C, PC]) ApproveSm() error {
+	return e.transition(TransitionedEventKind, types.ApproveTxEvent, (*internal.TxStateMachine).Approve)
+}
+
+func (e *core[C, PC]) CancelSm() error {
+	return e.transition(TransitionedEventKind, types.CancelTxEvent, (*internal.TxStateMachine).Cancel)
+}
+
+// FireSm moves e to the state ev leads to from the current state of its workflow.
+func (e *core[C, PC]) FireSm(ev types.TxEvent) error {
+	return e.transition(TransitionedEventKind, ev, fire(ev))
+}
+
+func (e *core[C, PC]) IsCategorySm(c types.TxCategory) bool {
+	return e.query(isCategory(c))
+}
+
+func (e *core[C, PC]) query(fn func(m *internal.TxStateMachine) bool) bool {
+	if e == nil {
+		return false
+	}
+	return e.ask(e.state, e.self, fn)
+}
+
+func (e *core[C, PC]) transition(kind TransitionEventKind, ev types.TxEvent, fn func(m *internal.TxStateMachine) error) error {
+	return e.change(TransitionOutcome{Kind: kind, Event: ev}, fn)
+}
+
+// change runs fn on the state machine of e, then ticks, records and publishes the change described by o.
+// The attempt is reported to the observers of the workflow of e either way.
+func (e *core[C, PC]) change(o TransitionOutcome, fn func(m *internal.TxStateMachine) error) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.changeAt(o, e.WorkflowSm().Clock().Now, fn)
+}
+
+// changeAt is change ticking at the time now returns instead of the time of the clock of the workflow.
+func (e *core[C, PC]) changeAt(o TransitionOutcome, now func() time.Time, fn func(m *internal.TxStateMachine) error) error {
+	stateless := *e.state == ""
+	return e.apply(e.state, e.self, o, fn, func(o *TransitionOutcome) TransitionEvent {
+		w := e.WorkflowSm()
+		// the change takes its own time, which is later than UpdatedAt if the clock was not reset
+		at := now()
+		e.tickAt(at)
+		l, u := e.hostLifecycle(), e.hostUndo()
+		var replaced *time.Time
+		if l != nil {
+			replaced = l.enteredAt(*e.state)
+			if o.Kind == UndoneEventKind && u != nil {
+				l.restore(o.From, u.ReplacedAt)
+			}
+			if stateless || *e.state != o.From {
+				l.enter(*e.state, at)
+			}
+		}
+		if u != nil {
+			*u = Undo{}
+			if s := w.undoState(o.Kind, o.Event, o.From); s != "" {
+				*u = Undo{UndoState: s, ApprovedAt: &at, ReplacedAt: replaced}
+			}
+		}
+		if a := e.hostAssignment(); a != nil && o.Kind == AssignedEventKind {
+			*a = Assignment{Assignee: o.Assignee, AssignedAt: &at, AssignReason: o.Reason}
+		} else if a != nil && !w.isPending(*e.state) {
+			*a = Assignment{}
+		}
+		o.Version = e.version()
+		return e.record(e.event(*o, at))
+	})
+}
+
+// SetHostSm tells e the struct embedding it, which names the entity in published transitions.
+// See Identifier and EntityTyper.
+func (e *core[C, PC]) SetHostSm(host any) {
+	if e == nil {
+		return
+	}
+	e.host = host
+}
+
+// SetActorSm sets who makes the next change of e, for authorizers, logs, observers and published transitions.
+// The actor is cleared once the change is attempted, whether it succeeds or not.
+func (e *core[C, PC]) SetActorSm(actor string) {
+	if e == nil {
+		return
+	}
+	e.actor = actor
+}
+
+// ActorSm returns the actor given to SetActorSm for the next change.
+func (e *core[C, PC]) ActorSm() string {
+	if e == nil {
+		return ""
+	}
+	return e.actor
+}
+
+// SetWorkflowSm makes e follow w instead of DefaultWorkflow.
+// It fails if e already has a state that w does not declare.
+func (e *core[C, PC]) SetWorkflowSm(w *Workflow) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.setWorkflow(*e.state, w)
+}
+
+// WorkflowSm returns the workflow e follows.
+func (e *core[C, PC]) WorkflowSm() *Workflow {
+	if e == nil {
+		return DefaultWorkflow()
+	}
+	return e.workflowSm()
+}
+
+// Tick ticks Clock. Clocks keeping times, like TxClock, take the time of the clock of the workflow of e.
+func (e *core[C, PC]) Tick() {
+	e.tickAt(e.WorkflowSm().Clock().Now())
+}
+
+// tickAt ticks Clock at now if it keeps times, and with the HLCSource of the workflow of e if it is hybrid.
+func (e *core[C, PC]) tickAt(now time.Time) {
+	c := e.clock
+	if h, ok := any(c).(interface {
+		tickHLC(now time.Time, hlc *HLCSource)
+	}); ok {
+		h.tickHLC(now, e.WorkflowSm().HLCSource())
+		return
+	}
+	if t, ok := any(c).(timed); ok {
+		t.tickAt(now)
+		return
+	}
+	c.Tick()
+}
+
+// ResetTicked lets the next transition tick Clock again.
+func (e *core[C, PC]) ResetTicked() {
+	e.clock.ResetTicked()
+}
+
+// version returns the version of Clock, zero if it counts no versions.
+func (e *core[C, PC]) version() uint64 {
+	if v, ok := any(e.clock).(versioned); ok {
+		return v.version()
+	}
+	return 0
+}
+
+// times returns when Clock was first and last ticked, nil if it keeps no times.
+func (e *core[C, PC]) times() (createdAt, updatedAt *time.Time) {
+	if t, ok := any(e.clock).(timed); ok {
+		return t.times()
+	}
+	return nil, nil
+}
+
+// restoreClock sets Clock as it was saved without ticking, if it keeps times.
+func (e *core[C, PC]) restoreClock(version uint64, createdAt, updatedAt *time.Time) {
+	c := e.clock
+	if t, ok := any(c).(timed); ok {
+		t.restore(version, createdAt, updatedAt)
+	}
+	c.ResetTicked()
+}
+
+// unitSaved is an entity as it was when its outermost unit of work began.
+type unitSaved[C any] struct {
+	state   types.TxState
+	clock   C
+	machine machine
+	host    func()
+}
+
+// BeginUnit starts a unit of work on e, in which transitions tick its clock once.
+// Units nest, see CommitUnit and RollbackUnit. UnitOfWork does this for several entities.
+func (e *core[C, PC]) BeginUnit() {
+	if e.unit == 0 {
+		saved := &unitSaved[C]{state: *e.state, clock: *e.clock, machine: *e.machine, host: e.saveHost()}
+		saved.machine.uncommitted = append([]TransitionEvent(nil), e.uncommitted...)
+		e.unitSaved = saved
+		e.ResetTicked()
+	}
+	e.unit++
+}
+
+// CommitUnit ends the innermost unit of work on e. Ending the outermost one resets its clock, so that it ticks again,
+// and publishes the transitions made during the unit.
+func (e *core[C, PC]) CommitUnit() {
+	if e.unit == 0 {
+		return
+	}
+	e.unit--
+	if e.unit == 0 {
+		e.unitSaved = nil
+		e.ResetTicked()
+		e.flush()
+	}
+}
+
+// RollbackUnit ends every unit of work on e and restores its state, clock, events and the parts its host keeps,
+// like Lifecycle, Assignment, Undo and EventSequence, as they were when the outermost one began.
+// The transitions made during the unit are not published.
+func (e *core[C, PC]) RollbackUnit() {
+	if e.unit == 0 {
+		return
+	}
+	saved := e.unitSaved.(*unitSaved[C])
+	saved.host()
+	*e.state, *e.clock, *e.machine = saved.state, saved.clock, saved.machine
+	e.unpublished = nil
+	e.ResetTicked()
+}
+
+// saveHost returns a function restoring the parts of e its host keeps, like Lifecycle, Assignment, Undo and EventSequence, as they are now.
+func (e *core[C, PC]) saveHost() func() {
+	var restore []func()
+	if l := e.hostLifecycle(); l != nil {
+		saved := l.clone()
+		restore = append(restore, func() { *l = saved })
+	}
+	if a := e.hostAssignment(); a != nil {
+		saved := *a
+		restore = append(restore, func() { *a = saved })
+	}
+	if u := e.hostUndo(); u != nil {
+		saved := *u
+		restore = append(restore, func() { *u = saved })
+	}
+	if seq := e.seq(); seq != &e.sequence {
+		saved := *seq
+		restore = append(restore, func() { *seq = saved })
+	}
+	return func() {
+		for _, fn := range restore {
+			fn()
+		}
+	}
+}
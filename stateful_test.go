@@ -0,0 +1,209 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+// sm is what every state machine embeddable provides, so that they share one test suite.
+type sm interface {
+	EqualSm(s types.TxState) bool
+	IsPendingSm() bool
+	IsActiveSm() bool
+	IsCanceledSm() bool
+	IsCategorySm(c types.TxCategory) bool
+	InitSm(s types.TxState) error
+	ForceStateSm(s types.TxState) error
+	OverrideSm(r Override) error
+	ImportSm(r Import) error
+	UndoSm() error
+	AssignSm(assignee, reason string) error
+	PendingSm() error
+	ModifyPendingSm() error
+	RemovePendingSm() error
+	ApproveSm() error
+	CancelSm() error
+	FireSm(ev types.TxEvent) error
+	SetActorSm(actor string)
+	ActorSm() string
+	SetHostSm(host any)
+	SetWorkflowSm(w *Workflow) error
+	WorkflowSm() *Workflow
+}
+
+// suiteHost keeps the optional parts of the state machines of the suite.
+type suiteHost struct {
+	Lifecycle
+	Assignment
+	Undo
+}
+
+func testSm(t *testing.T, newSm func() sm, versioned bool) {
+	t.Run("lifecycle", func(t *testing.T) {
+		e := newSm()
+		require.False(t, e.IsPendingSm())
+		require.Nil(t, e.PendingSm())
+		require.True(t, e.IsPendingSm())
+		require.Nil(t, e.ModifyPendingSm())
+		require.Nil(t, e.ApproveSm())
+		require.True(t, e.IsActiveSm())
+		require.True(t, e.IsCategorySm(types.ActiveTxCategory))
+		require.NotNil(t, e.PendingSm())
+		require.Nil(t, e.RemovePendingSm())
+		require.Nil(t, e.CancelSm())
+		require.True(t, e.EqualSm(types.ActiveTxState))
+	})
+
+	t.Run("force", func(t *testing.T) {
+		e := newSm()
+		require.ErrorIs(t, e.ForceStateSm(types.CanceledTxState), ErrForbidden)
+		unrestricted, err := DefaultWorkflow().With(WithUnrestrictedForce())
+		require.Nil(t, err)
+		require.Nil(t, e.SetWorkflowSm(unrestricted))
+		require.Nil(t, e.ForceStateSm(types.CanceledTxState))
+		require.True(t, e.IsCanceledSm())
+		require.NotNil(t, e.ForceStateSm("unknown"))
+		require.ErrorIs(t, e.OverrideSm(Override{To: types.ActiveTxState, Reason: "reopened"}), ErrForbidden)
+
+		w, err := DefaultWorkflow().With(WithForcePolicy(ForcePolicy{Authorize: allowForce}))
+		require.Nil(t, err)
+		require.Nil(t, e.SetWorkflowSm(w))
+		require.ErrorIs(t, e.OverrideSm(Override{To: types.ActiveTxState}), ErrReasonRequired)
+		require.Nil(t, e.OverrideSm(Override{To: types.ActiveTxState, Reason: "reopened"}))
+		require.True(t, e.IsActiveSm())
+	})
+
+	t.Run("workflow", func(t *testing.T) {
+		w, err := NewWorkflow("ticket", "open",
+			[]types.TxStateSpec{{Name: "open"}, {Name: "closed", Terminal: true}},
+			[]types.TxTransition{{From: "open", Event: "close", To: "closed"}})
+		require.Nil(t, err)
+
+		var outcomes []TransitionOutcome
+		w, err = w.With(WithObserver(TransitionObserverFunc(func(o TransitionOutcome) {
+			outcomes = append(outcomes, o)
+		})))
+		require.Nil(t, err)
+
+		e := newSm()
+		require.Nil(t, e.SetWorkflowSm(w))
+		require.Same(t, w, e.WorkflowSm())
+		e.SetActorSm("alice")
+		require.Nil(t, e.InitSm(""))
+		require.NotNil(t, e.FireSm("reopen"))
+		e.SetActorSm("alice")
+		require.Nil(t, e.FireSm("close"))
+		require.Equal(t, "", e.ActorSm())
+		require.True(t, e.EqualSm("closed"))
+
+		require.Len(t, outcomes, 3)
+		require.Equal(t, InitializedEventKind, outcomes[0].Kind)
+		require.EqualValues(t, "open", outcomes[0].To)
+		require.Equal(t, "alice", outcomes[0].Actor)
+		// the actor is given for one change only
+		require.Equal(t, "", outcomes[1].Actor)
+		require.Equal(t, FailedResult, outcomes[1].Result)
+		require.Equal(t, NotPermittedError, outcomes[1].ErrorKind)
+		require.Equal(t, SucceededResult, outcomes[2].Result)
+		require.EqualValues(t, "open", outcomes[2].From)
+		require.EqualValues(t, "closed", outcomes[2].To)
+		require.Equal(t, "alice", outcomes[2].Actor)
+		if versioned {
+			require.EqualValues(t, 1, outcomes[2].Version)
+		} else {
+			require.Zero(t, outcomes[2].Version)
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		w, err := DefaultWorkflow().With(WithUndoWindow(time.Hour))
+		require.Nil(t, err)
+		e := newSm()
+		require.Nil(t, e.SetWorkflowSm(w))
+		created := time.Now().Add(-48 * time.Hour)
+		require.Nil(t, e.ImportSm(Import{State: types.PendingTxState, Version: 7, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}))
+		require.True(t, e.IsPendingSm())
+		// assignments and undos are kept by the host
+		require.NotNil(t, e.AssignSm("bob", "on duty"))
+		require.Nil(t, e.ApproveSm())
+		require.NotNil(t, e.UndoSm())
+
+		e = newSm()
+		require.Nil(t, e.SetWorkflowSm(w))
+		e.SetHostSm(&suiteHost{})
+		require.Nil(t, e.ImportSm(Import{State: types.PendingTxState, Version: 7, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}))
+		require.Nil(t, e.AssignSm("bob", "on duty"))
+		require.Nil(t, e.ApproveSm())
+		require.Nil(t, e.UndoSm())
+		require.True(t, e.IsPendingSm())
+		require.NotNil(t, e.UndoSm())
+	})
+
+	t.Run("init", func(t *testing.T) {
+		e := newSm()
+		require.False(t, e.IsActiveSm())
+		require.False(t, e.EqualSm(types.PendingTxState))
+		require.True(t, e.EqualSm(""))
+
+		err := e.ApproveSm()
+		require.ErrorIs(t, err, ErrUninitialized)
+		require.Equal(t, UninitializedError, ErrorKindOf(err))
+		require.ErrorIs(t, e.ModifyPendingSm(), ErrUninitialized)
+		require.True(t, e.EqualSm(""))
+
+		w, err := DefaultWorkflow().With(WithInitialState(types.ActiveTxState))
+		require.Nil(t, err)
+		require.Nil(t, e.SetWorkflowSm(w))
+		require.Nil(t, e.InitSm(""))
+		require.True(t, e.IsActiveSm())
+		require.NotNil(t, e.InitSm(types.PendingTxState))
+		require.True(t, e.IsActiveSm())
+
+		e = newSm()
+		require.NotNil(t, e.InitSm("unknown"))
+		require.Nil(t, e.InitSm(types.InactiveTxState))
+		require.True(t, e.EqualSm(types.InactiveTxState))
+	})
+}
+
+func Test_TxStateMachine_suite(t *testing.T) {
+	testSm(t, func() sm { return &TxStateMachine{} }, false)
+}
+
+func Test_TxStateMachineClock_suite(t *testing.T) {
+	testSm(t, func() sm { return &TxStateMachineClock{} }, true)
+}
+
+func Test_Stateful_TxClock_suite(t *testing.T) {
+	testSm(t, func() sm { return &Stateful[TxClock, *TxClock]{} }, true)
+}
+
+func Test_Stateful_TxHybridClock_suite(t *testing.T) {
+	testSm(t, func() sm { return &Stateful[TxHybridClock, *TxHybridClock]{} }, true)
+}
+
+func Test_Stateful_NoClock_suite(t *testing.T) {
+	testSm(t, func() sm { return &Stateful[NoClock, *NoClock]{} }, false)
+}
+
+func Test_Stateful_ticks_clock(t *testing.T) {
+	var e Stateful[TxClock, *TxClock]
+	require.Nil(t, e.PendingSm())
+	require.EqualValues(t, 1, e.Clock.Version)
+	require.NotNil(t, e.Clock.UpdatedAt)
+
+	// a clock ticks once until it is reset
+	require.Nil(t, e.ApproveSm())
+	require.EqualValues(t, 1, e.Clock.Version)
+	e.Clock.ResetTicked()
+	require.Nil(t, e.RemovePendingSm())
+	require.EqualValues(t, 2, e.Clock.Version)
+
+	b, err := json.Marshal(&e)
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"state":"remove_pending","clock":{"version":2,`)
+}
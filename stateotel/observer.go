@@ -0,0 +1,116 @@
+// Package stateotel records transitions as OpenTelemetry spans and metrics.
+//
+//	o, err := stateotel.NewObserver()
+//	w, err := state.DefaultWorkflow().With(state.WithObserver(o))
+//
+// Transition methods take no context, so spans have no parent.
+package stateotel
+
+import (
+	"context"
+
+	"github.com/wonksing/state"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of spans and metrics.
+const ScopeName = "github.com/wonksing/state"
+
+// Observer records a span, a count and a duration for every transition.
+// The result attribute is "succeeded", or the state.TransitionErrorKind of a failure.
+type Observer struct {
+	tracer      trace.Tracer
+	transitions metric.Int64Counter
+	duration    metric.Float64Histogram
+}
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures an Observer.
+type Option func(o *options)
+
+// WithTracerProvider sets the provider of the tracer. The default is the global provider.
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = p
+	}
+}
+
+// WithMeterProvider sets the provider of the meter. The default is the global provider.
+func WithMeterProvider(p metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = p
+	}
+}
+
+func NewObserver(opts ...Option) (*Observer, error) {
+	o := options{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meter := o.meterProvider.Meter(ScopeName)
+	transitions, err := meter.Int64Counter("state.transitions",
+		metric.WithDescription("Number of transitions attempted."))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("state.transition.duration",
+		metric.WithDescription("Time taken by transitions."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return &Observer{
+		tracer:      o.tracerProvider.Tracer(ScopeName),
+		transitions: transitions,
+		duration:    duration,
+	}, nil
+}
+
+func (o *Observer) ObserveTransition(v state.TransitionOutcome) {
+	event := string(v.Event)
+	if event == "" {
+		event = string(v.Kind)
+	}
+	result := string(v.Result)
+	if v.Err != nil {
+		result = string(v.ErrorKind)
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("state.from", string(v.From)),
+		attribute.String("state.to", string(v.To)),
+		attribute.String("state.event", event),
+		attribute.String("state.result", result),
+	}
+
+	ctx := context.Background()
+	_, span := o.tracer.Start(ctx, "state.transition",
+		trace.WithTimestamp(v.Start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("state.workflow", v.Workflow),
+			attribute.String("state.entity.type", v.EntityType),
+			attribute.String("state.entity.id", v.EntityID),
+			attribute.Int64("state.version", int64(v.Version)),
+		))
+	if v.Err != nil {
+		span.RecordError(v.Err)
+		span.SetStatus(codes.Error, v.Err.Error())
+	}
+	span.End(trace.WithTimestamp(v.Start.Add(v.Latency)))
+
+	set := metric.WithAttributes(attrs...)
+	o.transitions.Add(ctx, 1, set)
+	o.duration.Record(ctx, v.Latency.Seconds(), set)
+}
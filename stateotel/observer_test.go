@@ -0,0 +1,70 @@
+package stateotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Observer(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	o, err := NewObserver(WithTracerProvider(tp), WithMeterProvider(mp))
+	require.Nil(t, err)
+	w, err := state.DefaultWorkflow().With(state.WithObserver(o))
+	require.Nil(t, err)
+	e := &state.TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	require.NotNil(t, e.CancelSm())
+
+	stubs := spans.GetSpans()
+	require.Len(t, stubs, 3)
+	require.Equal(t, "state.transition", stubs[1].Name)
+	require.Contains(t, stubs[1].Attributes, attribute.String("state.event", "approve"))
+	require.Contains(t, stubs[1].Attributes, attribute.String("state.to", "active"))
+	require.Contains(t, stubs[1].Attributes, attribute.Int64("state.version", 2))
+	require.Equal(t, codes.Unset, stubs[1].Status.Code)
+	require.Equal(t, codes.Error, stubs[2].Status.Code)
+	require.Contains(t, stubs[2].Attributes, attribute.String("state.result", "not_permitted"))
+	require.Len(t, stubs[2].Events, 1)
+
+	var rm metricdata.ResourceMetrics
+	require.Nil(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := make(map[string]metricdata.Metrics)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	sum := metrics["state.transitions"].Data.(metricdata.Sum[int64])
+	require.Len(t, sum.DataPoints, 3)
+	var total int64
+	for _, p := range sum.DataPoints {
+		total += p.Value
+		if v, _ := p.Attributes.Value("state.result"); v.AsString() == "not_permitted" {
+			require.Equal(t, int64(1), p.Value)
+			ev, _ := p.Attributes.Value("state.event")
+			require.Equal(t, "cancel", ev.AsString())
+		}
+	}
+	require.Equal(t, int64(3), total)
+
+	hist := metrics["state.transition.duration"].Data.(metricdata.Histogram[float64])
+	require.Len(t, hist.DataPoints, 3)
+	require.Equal(t, "s", metrics["state.transition.duration"].Unit)
+}
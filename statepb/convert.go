@@ -0,0 +1,180 @@
+package statepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative state.proto
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TxStateToProto converts s to its enum value. An empty state becomes TX_STATE_UNSPECIFIED.
+func TxStateToProto(s types.TxState) (TxState, error) {
+	if s == "" {
+		return TxState_TX_STATE_UNSPECIFIED, nil
+	}
+	c := s.Code()
+	if _, ok := TxState_name[int32(c)]; !ok || c == types.UnknownTxStateCode {
+		return TxState_TX_STATE_UNSPECIFIED, fmt.Errorf("state has no proto value: %q", s)
+	}
+	return TxState(c), nil
+}
+
+// TxStateFromProto converts v to types.TxState. TX_STATE_UNSPECIFIED becomes an empty state.
+func TxStateFromProto(v TxState) (types.TxState, error) {
+	if v == TxState_TX_STATE_UNSPECIFIED {
+		return "", nil
+	}
+	if _, ok := TxState_name[int32(v)]; !ok {
+		return "", fmt.Errorf("unknown proto state: %d", v)
+	}
+	s := types.TxStateCode(v).State()
+	if s == "" {
+		return "", fmt.Errorf("proto state is not registered: %s", v)
+	}
+	return s, nil
+}
+
+// ClockToProto converts c to TxClock.
+func ClockToProto(c *state.TxClock) *TxClock {
+	if c == nil {
+		return nil
+	}
+	return &TxClock{
+		Version:   c.Version,
+		CreatedAt: timeToProto(c.CreatedAt),
+		UpdatedAt: timeToProto(c.UpdatedAt),
+	}
+}
+
+// ClockFromProto converts m to state.TxClock.
+func ClockFromProto(m *TxClock) (state.TxClock, error) {
+	if m == nil {
+		return state.TxClock{}, nil
+	}
+	createdAt, err := timeFromProto(m.GetCreatedAt())
+	if err != nil {
+		return state.TxClock{}, err
+	}
+	updatedAt, err := timeFromProto(m.GetUpdatedAt())
+	if err != nil {
+		return state.TxClock{}, err
+	}
+	return state.TxClock{
+		Version:   m.GetVersion(),
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}, nil
+}
+
+// ToProto converts e to TxStateMachineClock.
+func ToProto(e *state.TxStateMachineClock) (*TxStateMachineClock, error) {
+	if e == nil {
+		return nil, nil
+	}
+	s, err := TxStateToProto(e.State)
+	if err != nil {
+		return nil, err
+	}
+	return &TxStateMachineClock{
+		State: s,
+		Clock: &TxClock{
+			Version:   e.Version,
+			CreatedAt: timeToProto(e.CreatedAt),
+			UpdatedAt: timeToProto(e.UpdatedAt),
+		},
+	}, nil
+}
+
+// FromProto sets the state and clock of e to those of m, without ticking.
+// The workflow, host and events of e are kept. e is left untouched if m holds an invalid state or timestamp.
+func FromProto(m *TxStateMachineClock, e *state.TxStateMachineClock) error {
+	if e == nil {
+		return fmt.Errorf("destination is nil")
+	}
+	if m == nil {
+		return fmt.Errorf("source is nil")
+	}
+	s, err := TxStateFromProto(m.GetState())
+	if err != nil {
+		return err
+	}
+	c, err := ClockFromProto(m.GetClock())
+	if err != nil {
+		return err
+	}
+
+	e.State, e.TxClock = s, c
+	return nil
+}
+
+// TransitionToProto converts the states, event, version and time of ev to TxTransition.
+func TransitionToProto(ev state.TransitionEvent) (*TxTransition, error) {
+	from, err := TxStateToProto(ev.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := TxStateToProto(ev.To)
+	if err != nil {
+		return nil, err
+	}
+	return &TxTransition{
+		From:       from,
+		To:         to,
+		Event:      string(ev.Event),
+		Version:    ev.Version,
+		OccurredAt: timeToProto(&ev.OccurredAt),
+	}, nil
+}
+
+// TransitionFromProto converts m to a state.TransitionEvent of kind state.TransitionedEventKind.
+// TxTransition does not carry the sequence, kind, reason or assignee of an event.
+func TransitionFromProto(m *TxTransition) (state.TransitionEvent, error) {
+	if m == nil {
+		return state.TransitionEvent{}, fmt.Errorf("source is nil")
+	}
+	from, err := TxStateFromProto(m.GetFrom())
+	if err != nil {
+		return state.TransitionEvent{}, err
+	}
+	to, err := TxStateFromProto(m.GetTo())
+	if err != nil {
+		return state.TransitionEvent{}, err
+	}
+	at, err := timeFromProto(m.GetOccurredAt())
+	if err != nil {
+		return state.TransitionEvent{}, err
+	}
+	ev := state.TransitionEvent{
+		Kind:    state.TransitionedEventKind,
+		Event:   types.TxEvent(m.GetEvent()),
+		From:    from,
+		To:      to,
+		Version: m.GetVersion(),
+	}
+	if at != nil {
+		ev.OccurredAt = *at
+	}
+	return ev, nil
+}
+
+func timeToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, err
+	}
+	t := ts.AsTime()
+	return &t, nil
+}
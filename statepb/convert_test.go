@@ -0,0 +1,151 @@
+package statepb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func Test_TxState_enum_matches_code(t *testing.T) {
+	for _, c := range types.TxStateCodes() {
+		s := c.State()
+		v, err := TxStateToProto(s)
+		require.Nil(t, err)
+		require.EqualValues(t, c, v)
+
+		back, err := TxStateFromProto(v)
+		require.Nil(t, err)
+		require.EqualValues(t, s, back)
+	}
+	require.Len(t, TxState_name, len(types.TxStateCodes())+1)
+}
+
+func Test_TxStateToProto_invalid(t *testing.T) {
+	_, err := TxStateToProto(types.TxState("unknown"))
+	require.NotNil(t, err)
+
+	_, err = TxStateFromProto(TxState(100))
+	require.NotNil(t, err)
+
+	s, err := TxStateFromProto(TxState_TX_STATE_UNSPECIFIED)
+	require.Nil(t, err)
+	require.EqualValues(t, "", s)
+}
+
+func Test_TxStateMachineClock_round_trip(t *testing.T) {
+	p := &person{Name: "John"}
+	require.Nil(t, p.PendingSm())
+	require.Nil(t, p.ApproveSm())
+
+	m, err := ToProto(&p.TxStateMachineClock)
+	require.Nil(t, err)
+	require.EqualValues(t, TxState_TX_STATE_ACTIVE, m.State)
+
+	b, err := proto.Marshal(m)
+	require.Nil(t, err)
+	decoded := &TxStateMachineClock{}
+	require.Nil(t, proto.Unmarshal(b, decoded))
+
+	w, err := state.DefaultWorkflow().With(state.WithEventSourcing())
+	require.Nil(t, err)
+	q := &person{Name: "John"}
+	require.Nil(t, q.SetWorkflowSm(w))
+	require.Nil(t, FromProto(decoded, &q.TxStateMachineClock))
+	// the workflow of q is kept
+	require.Same(t, w, q.WorkflowSm())
+	require.EqualValues(t, p.State, q.State)
+	require.EqualValues(t, p.Version, q.Version)
+	require.True(t, p.CreatedAt.Equal(*q.CreatedAt))
+	require.True(t, p.UpdatedAt.Equal(*q.UpdatedAt))
+
+	// the restored entity keeps working
+	require.Nil(t, q.RemovePendingSm())
+	require.True(t, q.IsRemovePendingSm())
+	require.EqualValues(t, p.Version+1, q.Version)
+	require.Len(t, q.UncommittedEventsSm(), 1)
+}
+
+func Test_FromProto_invalid(t *testing.T) {
+	e := state.TxStateMachineClock{State: types.ActiveTxState, TxClock: state.TxClock{Version: 3}}
+
+	err := FromProto(&TxStateMachineClock{State: TxState(42)}, &e)
+	require.NotNil(t, err)
+	require.EqualValues(t, types.ActiveTxState, e.State)
+
+	err = FromProto(&TxStateMachineClock{
+		State: TxState_TX_STATE_REMOVED,
+		Clock: &TxClock{CreatedAt: &timestamppb.Timestamp{Nanos: -1}},
+	}, &e)
+	require.NotNil(t, err)
+	require.EqualValues(t, 3, e.Version)
+
+	_, err = ToProto(&state.TxStateMachineClock{State: types.TxState("unknown")})
+	require.NotNil(t, err)
+}
+
+func Test_TxClock_round_trip(t *testing.T) {
+	now := time.Now()
+	c := &state.TxClock{Version: 7, CreatedAt: &now, UpdatedAt: &now}
+
+	back, err := ClockFromProto(ClockToProto(c))
+	require.Nil(t, err)
+	require.EqualValues(t, 7, back.Version)
+	require.True(t, now.Equal(*back.CreatedAt))
+	require.True(t, now.Equal(*back.UpdatedAt))
+
+	back, err = ClockFromProto(ClockToProto(&state.TxClock{}))
+	require.Nil(t, err)
+	require.Nil(t, back.CreatedAt)
+	require.Nil(t, back.UpdatedAt)
+}
+
+func Test_TxTransition_round_trip(t *testing.T) {
+	at := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	ev := state.TransitionEvent{
+		Kind:       state.TransitionedEventKind,
+		Event:      types.ApproveTxEvent,
+		From:       types.PendingTxState,
+		To:         types.ActiveTxState,
+		Version:    2,
+		OccurredAt: at,
+	}
+	m, err := TransitionToProto(ev)
+	require.Nil(t, err)
+	require.EqualValues(t, TxState_TX_STATE_PENDING, m.From)
+	require.EqualValues(t, TxState_TX_STATE_ACTIVE, m.To)
+
+	b, err := proto.Marshal(m)
+	require.Nil(t, err)
+	decoded := &TxTransition{}
+	require.Nil(t, proto.Unmarshal(b, decoded))
+	back, err := TransitionFromProto(decoded)
+	require.Nil(t, err)
+	require.Equal(t, ev, back)
+
+	// an entity without a state is converted from and to TX_STATE_UNSPECIFIED
+	m, err = TransitionToProto(state.TransitionEvent{Kind: state.InitializedEventKind, To: types.PendingTxState, OccurredAt: at})
+	require.Nil(t, err)
+	require.EqualValues(t, TxState_TX_STATE_UNSPECIFIED, m.From)
+}
+
+func Test_TxTransition_invalid(t *testing.T) {
+	_, err := TransitionToProto(state.TransitionEvent{From: types.PendingTxState, To: "unknown"})
+	require.NotNil(t, err)
+
+	_, err = TransitionFromProto(nil)
+	require.NotNil(t, err)
+	_, err = TransitionFromProto(&TxTransition{From: TxState(42)})
+	require.NotNil(t, err)
+	_, err = TransitionFromProto(&TxTransition{OccurredAt: &timestamppb.Timestamp{Nanos: -1}})
+	require.NotNil(t, err)
+}
+
+type person struct {
+	Name string
+	state.TxStateMachineClock
+}
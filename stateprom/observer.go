@@ -0,0 +1,90 @@
+// Package stateprom exposes transitions as Prometheus metrics.
+//
+//	o := stateprom.NewObserver()
+//	prometheus.MustRegister(o)
+//	w, err := state.DefaultWorkflow().With(state.WithObserver(o))
+package stateprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wonksing/state"
+)
+
+var labels = []string{"from", "to", "event", "result"}
+
+// Observer counts transitions and measures their latency.
+// The result label is "succeeded", or the state.TransitionErrorKind of a failure.
+// The event label of ForceStateSm is "forced" and of InitSm "initialized".
+type Observer struct {
+	transitions *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+}
+
+type options struct {
+	namespace string
+	buckets   []float64
+}
+
+// Option configures an Observer.
+type Option func(o *options)
+
+// WithNamespace prefixes the metric names with namespace.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets sets the buckets of the latency histogram in seconds. The default is prometheus.DefBuckets.
+func WithBuckets(buckets ...float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+func NewObserver(opts ...Option) *Observer {
+	o := options{buckets: prometheus.DefBuckets}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Observer{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "state_transitions_total",
+			Help:      "Number of transitions attempted.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "state_transition_duration_seconds",
+			Help:      "Time taken by transitions.",
+			Buckets:   o.buckets,
+		}, labels),
+	}
+}
+
+func (o *Observer) ObserveTransition(v state.TransitionOutcome) {
+	event := string(v.Event)
+	if event == "" {
+		event = string(v.Kind)
+	}
+	result := string(v.Result)
+	if v.Err != nil {
+		result = string(v.ErrorKind)
+	}
+
+	lv := []string{string(v.From), string(v.To), event, result}
+	o.transitions.WithLabelValues(lv...).Inc()
+	o.latency.WithLabelValues(lv...).Observe(v.Latency.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (o *Observer) Describe(ch chan<- *prometheus.Desc) {
+	o.transitions.Describe(ch)
+	o.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (o *Observer) Collect(ch chan<- prometheus.Metric) {
+	o.transitions.Collect(ch)
+	o.latency.Collect(ch)
+}
@@ -0,0 +1,43 @@
+package stateprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+)
+
+func Test_Observer(t *testing.T) {
+	o := NewObserver(WithNamespace("test"))
+	reg := prometheus.NewPedanticRegistry()
+	require.Nil(t, reg.Register(o))
+
+	w, err := state.DefaultWorkflow().With(state.WithObserver(o), state.WithUnrestrictedForce())
+	require.Nil(t, err)
+	e := &state.TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	require.NotNil(t, e.ApproveSm())
+	require.Nil(t, e.ForceStateSm(types.CanceledTxState))
+
+	expected := `
+# HELP test_state_transitions_total Number of transitions attempted.
+# TYPE test_state_transitions_total counter
+test_state_transitions_total{event="approve",from="active",result="not_permitted",to=""} 1
+test_state_transitions_total{event="approve",from="pending",result="succeeded",to="active"} 1
+test_state_transitions_total{event="forced",from="active",result="succeeded",to="canceled"} 1
+test_state_transitions_total{event="pending",from="",result="succeeded",to="pending"} 1
+`
+	require.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "test_state_transitions_total"))
+
+	n, err := testutil.GatherAndCount(reg, "test_state_transition_duration_seconds")
+	require.Nil(t, err)
+	require.Equal(t, 4, n)
+}
@@ -0,0 +1,52 @@
+//go:build go1.21
+
+// Package stateslog writes transitions with log/slog.
+//
+//	w, err := state.DefaultWorkflow().With(state.WithLogger(stateslog.New(slog.Default())))
+package stateslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/wonksing/state"
+)
+
+// Logger is a state.TransitionLogger writing to a *slog.Logger.
+// Transitions are written at slog.LevelInfo, forced or overridden states at slog.LevelWarn with their reason,
+// and transitions denied or failed at slog.LevelWarn with their error.
+type Logger struct {
+	l *slog.Logger
+}
+
+func New(l *slog.Logger) *Logger {
+	return &Logger{l: l}
+}
+
+func (l *Logger) LogTransition(r state.TransitionLogRecord) {
+	level := slog.LevelInfo
+	if r.Level >= state.WarnLogLevel {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("workflow", r.Workflow),
+		slog.String("entity_type", r.EntityType),
+		slog.String("entity_id", r.EntityID),
+		slog.String("kind", string(r.Kind)),
+		slog.String("event", string(r.Event)),
+		slog.String("from", string(r.From)),
+		slog.String("to", string(r.To)),
+		slog.Uint64("version", r.Version),
+		slog.String("actor", r.Actor),
+	}
+	if r.Assignee != "" {
+		attrs = append(attrs, slog.String("assignee", r.Assignee))
+	}
+	if r.Reason != "" {
+		attrs = append(attrs, slog.String("reason", r.Reason))
+	}
+	if r.Err != nil {
+		attrs = append(attrs, slog.String("error_kind", string(r.ErrorKind)), slog.String("error", r.Err.Error()))
+	}
+	l.l.LogAttrs(context.Background(), level, r.Message, attrs...)
+}
@@ -0,0 +1,91 @@
+//go:build go1.21
+
+package stateslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state"
+	"github.com/wonksing/state/types"
+)
+
+type account struct {
+	ID string
+	state.TxStateMachineClock
+}
+
+func (a *account) EntityID() string {
+	return a.ID
+}
+
+func Test_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	w, err := state.DefaultWorkflow().With(state.WithLogger(l), state.WithUnrestrictedForce())
+	require.Nil(t, err)
+
+	a := &account{ID: "a-1"}
+	require.Nil(t, a.SetWorkflowSm(w))
+	a.SetHostSm(a)
+	a.SetActorSm("alice")
+	require.Nil(t, a.PendingSm())
+	require.NotNil(t, a.RemovePendingSm())
+	a.ResetTicked()
+	require.Nil(t, a.ForceStateSm(types.RemovedTxState))
+	a.ResetTicked()
+	w, err = w.With(state.WithForcePolicy(state.ForcePolicy{Authorize: func(state.ForceRequest) error { return nil }}))
+	require.Nil(t, err)
+	require.Nil(t, a.SetWorkflowSm(w))
+	require.Nil(t, a.OverrideSm(state.Override{To: types.ActiveTxState, Reason: "removed by mistake"}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+
+	var rec map[string]any
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &rec))
+	require.Equal(t, "INFO", rec["level"])
+	require.Equal(t, "state transitioned", rec["msg"])
+	require.Equal(t, "account", rec["entity_type"])
+	require.Equal(t, "a-1", rec["entity_id"])
+	require.Equal(t, "pending", rec["to"])
+	require.Equal(t, float64(1), rec["version"])
+	require.Equal(t, "alice", rec["actor"])
+
+	require.NotContains(t, rec, "error")
+
+	// pending does not lead to remove_pending
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &rec))
+	require.Equal(t, "WARN", rec["level"])
+	require.Equal(t, "transition failed", rec["msg"])
+	require.Equal(t, "pending", rec["from"])
+	require.Equal(t, "not_permitted", rec["error_kind"])
+	require.NotEmpty(t, rec["error"])
+
+	rec = nil
+	require.Nil(t, json.Unmarshal([]byte(lines[2]), &rec))
+	require.Equal(t, "WARN", rec["level"])
+	require.Equal(t, "state forced", rec["msg"])
+	require.Equal(t, "forced", rec["kind"])
+	require.Equal(t, "pending", rec["from"])
+	require.Equal(t, "removed", rec["to"])
+	require.Equal(t, float64(2), rec["version"])
+	require.NotContains(t, rec, "reason")
+
+	rec = nil
+	require.Nil(t, json.Unmarshal([]byte(lines[3]), &rec))
+	require.Equal(t, "WARN", rec["level"])
+	require.Equal(t, "state overridden", rec["msg"])
+	require.Equal(t, "removed by mistake", rec["reason"])
+}
@@ -9,18 +9,23 @@ type TxClock struct {
 
 	CreatedAt *time.Time `gorm:"<-:create;index:idx_created_at" json:"created_at,omitempty"`
 	UpdatedAt *time.Time `gorm:"<-;index:idx_updated_at" json:"updated_at,omitempty"`
+
+	unit      int      `gorm:"-:all" json:"-"`
+	unitSaved *TxClock `gorm:"-:all" json:"-"`
 }
 
-// Tick increments Version and set current time to CreatedAt and UpdatedAt.
+// Tick increments Version and set the time of DefaultClock to CreatedAt and UpdatedAt.
 // It returns immediately if Version is already incremented.
 func (e *TxClock) Tick() {
+	e.tickAt(DefaultClock().Now())
+}
+
+func (e *TxClock) tickAt(now time.Time) {
 	if e.VersionTicked {
 		return
 	}
 	e.VersionTicked = true
 	e.Version++
-
-	now := time.Now()
 	if e.CreatedAt == nil {
 		e.CreatedAt = &now
 	}
@@ -30,3 +35,48 @@ func (e *TxClock) Tick() {
 func (e *TxClock) ResetTicked() {
 	e.VersionTicked = false
 }
+
+// BeginUnit starts a unit of work on e, in which Tick bumps Version once.
+// Units nest, see CommitUnit and RollbackUnit. UnitOfWork does this for several entities.
+func (e *TxClock) BeginUnit() {
+	if e.unit == 0 {
+		saved := *e
+		e.unitSaved = &saved
+		e.VersionTicked = false
+	}
+	e.unit++
+}
+
+// CommitUnit ends the innermost unit of work on e. Ending the outermost one resets VersionTicked.
+func (e *TxClock) CommitUnit() {
+	if e.unit == 0 {
+		return
+	}
+	e.unit--
+	if e.unit == 0 {
+		e.unitSaved = nil
+		e.VersionTicked = false
+	}
+}
+
+// RollbackUnit ends every unit of work on e and restores e as it was when the outermost one began.
+func (e *TxClock) RollbackUnit() {
+	if e.unit == 0 {
+		return
+	}
+	*e = *e.unitSaved
+	e.VersionTicked = false
+}
+
+func (e *TxClock) version() uint64 {
+	return e.Version
+}
+
+func (e *TxClock) times() (createdAt, updatedAt *time.Time) {
+	return e.CreatedAt, e.UpdatedAt
+}
+
+func (e *TxClock) restore(version uint64, createdAt, updatedAt *time.Time) {
+	e.Version, e.CreatedAt, e.UpdatedAt = version, createdAt, updatedAt
+	e.VersionTicked = false
+}
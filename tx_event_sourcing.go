@@ -0,0 +1,308 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wonksing/state/types"
+)
+
+type TransitionEventKind string
+
+const (
+	// TransitionedEventKind is a change made by an event of the workflow.
+	TransitionedEventKind TransitionEventKind = "transitioned"
+	// ForcedEventKind is a change made by ForceStateSm.
+	ForcedEventKind TransitionEventKind = "forced"
+	// OverriddenEventKind is a change made by OverrideSm, allowed by the ForcePolicy of the workflow.
+	OverriddenEventKind TransitionEventKind = "overridden"
+	// InitializedEventKind is the first state of an entity given by InitSm.
+	InitializedEventKind TransitionEventKind = "initialized"
+	// UndoneEventKind is an approval reverted by UndoSm.
+	UndoneEventKind TransitionEventKind = "undone"
+	// ImportedEventKind is the state and clock of a legacy record given by ImportSm.
+	ImportedEventKind TransitionEventKind = "imported"
+	// AssignedEventKind is an entity in a pending state assigned by AssignSm or EscalateSm, which keeps its state.
+	AssignedEventKind TransitionEventKind = "assigned"
+)
+
+// TransitionEvent is a change of an entity recorded when its workflow uses WithEventSourcing.
+type TransitionEvent struct {
+	// Sequence numbers the events of an entity from 1 without gaps.
+	Sequence uint64              `json:"sequence"`
+	Kind     TransitionEventKind `json:"kind"`
+	Event    types.TxEvent       `json:"event,omitempty"`
+	From     types.TxState       `json:"from"`
+	To       types.TxState       `json:"to"`
+	// Reason is why the change was made, given to OverrideSm or AssignSm.
+	Reason string `json:"reason,omitempty"`
+	// Assignee is who the entity is assigned to by a change of kind AssignedEventKind.
+	Assignee string `json:"assignee,omitempty"`
+
+	// Version and OccurredAt are the version of the clock of the entity and the time of the change.
+	Version    uint64    `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// CreatedAt is when a record given to ImportSm was created, long before it was imported.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// Snapshot is an entity as of the event numbered Sequence.
+type Snapshot struct {
+	Sequence   uint64        `json:"sequence"`
+	State      types.TxState `json:"state"`
+	Version    uint64        `json:"version"`
+	CreatedAt  *time.Time    `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time    `json:"updated_at,omitempty"`
+	EnteredAt  StateTimes    `json:"entered_at,omitempty"`
+	UndoState  types.TxState `json:"undo_state,omitempty"`
+	ApprovedAt *time.Time    `json:"approved_at,omitempty"`
+	ReplacedAt *time.Time    `json:"replaced_at,omitempty"`
+
+	Assignee     string     `json:"assignee,omitempty"`
+	AssignedAt   *time.Time `json:"assigned_at,omitempty"`
+	AssignReason string     `json:"assign_reason,omitempty"`
+}
+
+// EventSequence keeps the sequence of the last event of an entity, so that an entity loaded again numbers
+// its next events after it. It is optional: embed it in the host of a state machine and give the host to SetHostSm.
+// The outbox requires it, since messages are told apart by their sequence.
+type EventSequence struct {
+	Sequence uint64 `gorm:"<-;column:sequence" json:"sequence,omitempty"`
+}
+
+func (s *EventSequence) eventSequence() *EventSequence {
+	return s
+}
+
+// SequenceSm returns the sequence of the last event of the entity.
+func (s *EventSequence) SequenceSm() uint64 {
+	return s.Sequence
+}
+
+// Sequenced is a host embedding EventSequence.
+type Sequenced interface {
+	SequenceSm() uint64
+}
+
+// Replay applies events to from, or to an entity without a state if from is nil, following w.
+// Events already covered by from are skipped, so a snapshot and the full stream may be passed together.
+// It fails if the events have a gap in their sequence, a version going back,
+// or a change w does not allow. Guards are not evaluated again.
+func Replay(w *Workflow, from *Snapshot, events []TransitionEvent) (Snapshot, error) {
+	if w == nil {
+		return Snapshot{}, errors.New("workflow is nil")
+	}
+
+	var s Snapshot
+	if from != nil {
+		s = *from
+		s.EnteredAt = from.EnteredAt.Clone()
+	}
+	for _, ev := range events {
+		if ev.Sequence <= s.Sequence {
+			continue
+		}
+		if ev.Sequence != s.Sequence+1 {
+			return Snapshot{}, fmt.Errorf("event %d is missing", s.Sequence+1)
+		}
+		if ev.Version < s.Version {
+			return Snapshot{}, fmt.Errorf("version of event %d goes back from %d to %d", ev.Sequence, s.Version, ev.Version)
+		}
+		if err := w.checkEvent(s.State, s.UndoState, ev); err != nil {
+			return Snapshot{}, fmt.Errorf("event %d: %w", ev.Sequence, err)
+		}
+
+		at := ev.OccurredAt
+		if s.EnteredAt == nil {
+			s.EnteredAt = make(StateTimes)
+		}
+		var replaced *time.Time
+		if v, ok := s.EnteredAt[ev.To]; ok {
+			replaced = &v
+		}
+		// an undo forgets when the approval entered its state
+		if ev.Kind == UndoneEventKind && s.ReplacedAt != nil {
+			s.EnteredAt[ev.From] = *s.ReplacedAt
+		} else if ev.Kind == UndoneEventKind {
+			delete(s.EnteredAt, ev.From)
+		}
+		if s.State == "" || s.State != ev.To {
+			s.EnteredAt[ev.To] = at
+		}
+		s.Sequence = ev.Sequence
+		s.State = ev.To
+		s.UndoState, s.ApprovedAt, s.ReplacedAt = w.undoState(ev.Kind, ev.Event, ev.From), nil, nil
+		if s.UndoState != "" {
+			s.ApprovedAt, s.ReplacedAt = &at, replaced
+		}
+		if ev.Kind == AssignedEventKind {
+			s.Assignee, s.AssignedAt, s.AssignReason = ev.Assignee, &at, ev.Reason
+		} else if !w.isPending(s.State) {
+			s.Assignee, s.AssignedAt, s.AssignReason = "", nil, ""
+		}
+		if ev.Kind == ImportedEventKind && ev.CreatedAt != nil {
+			created := *ev.CreatedAt
+			s.CreatedAt = &created
+		} else if s.CreatedAt == nil {
+			s.CreatedAt = &at
+		}
+		// a clock is updated when its version is bumped, once per unit of work
+		if s.UpdatedAt == nil || ev.Version != s.Version || ev.Version == 0 {
+			s.UpdatedAt = &at
+		}
+		s.Version = ev.Version
+	}
+	return s, nil
+}
+
+// checkEvent returns an error if w does not allow ev from current, which UndoSm may revert to undo.
+func (w *Workflow) checkEvent(current, undo types.TxState, ev TransitionEvent) error {
+	// an entity without a state starts at the initial state
+	if current == "" && ev.From == w.Initial() {
+		current = ev.From
+	}
+	if ev.From != current {
+		return fmt.Errorf("starts at %q but the entity is at %q", ev.From, current)
+	}
+	if !w.HasState(ev.To) {
+		return fmt.Errorf("state is invalid: %q", ev.To)
+	}
+
+	switch ev.Kind {
+	case ForcedEventKind, OverriddenEventKind:
+		return nil
+	case UndoneEventKind:
+		if undo == "" || undo != ev.To {
+			return fmt.Errorf("undoes no approval to %q", ev.To)
+		}
+		return nil
+	case AssignedEventKind:
+		if ev.From != ev.To || !w.isPending(ev.To) {
+			return fmt.Errorf("assigns an entity from %q to %q", ev.From, ev.To)
+		}
+		return nil
+	case InitializedEventKind, ImportedEventKind:
+		if ev.From != "" {
+			return fmt.Errorf("%s an entity at %q", ev.Kind, ev.From)
+		}
+		return nil
+	case TransitionedEventKind:
+		if t, ok := w.table.Lookup(ev.From, ev.Event); ok && t.To == ev.To {
+			return nil
+		}
+		// requesting the current state again is a no-op, see internal.TxStateMachine.SetState
+		if req, ok := types.RequestTxEvent(ev.To); ok && ev.From == ev.To && ev.Event == req {
+			return nil
+		}
+		return fmt.Errorf("%s does not lead from %q to %q", ev.Event, ev.From, ev.To)
+	}
+	return fmt.Errorf("unknown event kind: %q", ev.Kind)
+}
+
+// event returns the TransitionEvent of the change o of e at at.
+func (e *core[C, PC]) event(o TransitionOutcome, at time.Time) TransitionEvent {
+	return TransitionEvent{
+		Kind:       o.Kind,
+		Event:      o.Event,
+		From:       o.From,
+		To:         *e.state,
+		Reason:     o.Reason,
+		Assignee:   o.Assignee,
+		Version:    e.version(),
+		OccurredAt: at,
+	}
+}
+
+// record returns v. It is numbered and appended to the uncommitted events if the workflow of e uses WithEventSourcing.
+func (e *core[C, PC]) record(v TransitionEvent) TransitionEvent {
+	if !e.WorkflowSm().opts.eventSourcing {
+		return v
+	}
+
+	seq := e.seq()
+	*seq++
+	v.Sequence = *seq
+	e.uncommitted = append(e.uncommitted, v)
+	return v
+}
+
+// seq returns the sequence of the last event of e, kept by the EventSequence embedded by its host if there is one.
+func (e *core[C, PC]) seq() *uint64 {
+	if h, ok := e.host.(interface{ eventSequence() *EventSequence }); ok {
+		return &h.eventSequence().Sequence
+	}
+	return &e.sequence
+}
+
+// UncommittedEventsSm returns the events recorded since e was replayed or its events were cleared.
+func (e *core[C, PC]) UncommittedEventsSm() []TransitionEvent {
+	if e == nil {
+		return nil
+	}
+	return append([]TransitionEvent(nil), e.uncommitted...)
+}
+
+// ClearEventsSm forgets the uncommitted events, e.g. after they are stored.
+func (e *core[C, PC]) ClearEventsSm() {
+	if e == nil {
+		return
+	}
+	e.uncommitted = nil
+}
+
+// SnapshotSm returns e as of its last event.
+func (e *core[C, PC]) SnapshotSm() Snapshot {
+	if e == nil {
+		return Snapshot{}
+	}
+	createdAt, updatedAt := e.times()
+	var entered StateTimes
+	if l := e.hostLifecycle(); l != nil {
+		entered = l.EnteredAt.Clone()
+	}
+	s := Snapshot{
+		Sequence:  *e.seq(),
+		State:     *e.state,
+		Version:   e.version(),
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+		EnteredAt: entered,
+	}
+	if u := e.hostUndo(); u != nil {
+		s.UndoState, s.ApprovedAt, s.ReplacedAt = u.UndoState, u.ApprovedAt, u.ReplacedAt
+	}
+	if a := e.hostAssignment(); a != nil {
+		s.Assignee, s.AssignedAt, s.AssignReason = a.Assignee, a.AssignedAt, a.AssignReason
+	}
+	return s
+}
+
+// ReplaySm rebuilds e from events, starting from snapshot if it is not nil.
+// The uncommitted events are cleared, and new events are numbered after the last one replayed.
+// e is left untouched if Replay fails.
+func (e *core[C, PC]) ReplaySm(snapshot *Snapshot, events []TransitionEvent) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	s, err := Replay(e.WorkflowSm(), snapshot, events)
+	if err != nil {
+		return err
+	}
+
+	*e.state = s.State
+	e.restoreClock(s.Version, s.CreatedAt, s.UpdatedAt)
+	if l := e.hostLifecycle(); l != nil {
+		l.reset(s.EnteredAt)
+	}
+	if u := e.hostUndo(); u != nil {
+		*u = Undo{UndoState: s.UndoState, ApprovedAt: s.ApprovedAt, ReplacedAt: s.ReplacedAt}
+	}
+	if a := e.hostAssignment(); a != nil {
+		*a = Assignment{Assignee: s.Assignee, AssignedAt: s.AssignedAt, AssignReason: s.AssignReason}
+	}
+	*e.seq() = s.Sequence
+	e.uncommitted = nil
+	e.stateMachine = nil
+	return nil
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func newEventSourcedWorkflow(t *testing.T) *Workflow {
+	w, err := DefaultWorkflow().With(WithEventSourcing())
+	require.Nil(t, err)
+	return w
+}
+
+func Test_TxStateMachineClock_records_events(t *testing.T) {
+	w, err := newEventSourcedWorkflow(t).With(WithUnrestrictedForce())
+	require.Nil(t, err)
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.NotNil(t, e.CancelSm())
+	require.Nil(t, e.ModifyPendingSm())
+	require.Nil(t, e.CancelSm())
+	e.ResetTicked()
+	require.Nil(t, e.ForceStateSm(types.InactiveTxState))
+
+	events := e.UncommittedEventsSm()
+	require.Len(t, events, 5)
+	expected := []TransitionEvent{
+		{Sequence: 1, Kind: TransitionedEventKind, Event: types.PendingTxEvent, From: "", To: types.PendingTxState, Version: 1},
+		{Sequence: 2, Kind: TransitionedEventKind, Event: types.ApproveTxEvent, From: types.PendingTxState, To: types.ActiveTxState, Version: 2},
+		{Sequence: 3, Kind: TransitionedEventKind, Event: types.ModifyPendingTxEvent, From: types.ActiveTxState, To: types.ModifyPendingTxState, Version: 3},
+		{Sequence: 4, Kind: TransitionedEventKind, Event: types.CancelTxEvent, From: types.ModifyPendingTxState, To: types.ActiveTxState, Version: 3},
+		{Sequence: 5, Kind: ForcedEventKind, From: types.ActiveTxState, To: types.InactiveTxState, Version: 4},
+	}
+	for i, v := range events {
+		require.False(t, v.OccurredAt.IsZero())
+		v.OccurredAt = time.Time{}
+		require.EqualValues(t, expected[i], v)
+	}
+
+	e.ClearEventsSm()
+	require.Len(t, e.UncommittedEventsSm(), 0)
+}
+
+func Test_TxStateMachineClock_no_events_by_default(t *testing.T) {
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.PendingSm())
+	require.Nil(t, e.ApproveSm())
+	require.Len(t, e.UncommittedEventsSm(), 0)
+}
+
+func Test_Replay(t *testing.T) {
+	w := newEventSourcedWorkflow(t)
+	e := newTrackedOrder(t, w)
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.Nil(t, e.RemovePendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	events := e.UncommittedEventsSm()
+
+	s, err := Replay(w, nil, events)
+	require.Nil(t, err)
+	require.EqualValues(t, e.SnapshotSm(), s)
+	require.EqualValues(t, types.RemovedTxState, s.State)
+	require.EqualValues(t, 4, s.Version)
+	require.True(t, s.CreatedAt.Equal(events[0].OccurredAt))
+	require.True(t, s.UpdatedAt.Equal(events[3].OccurredAt))
+
+	// deterministic
+	again, err := Replay(w, nil, events)
+	require.Nil(t, err)
+	require.EqualValues(t, s, again)
+
+	// a snapshot skips the prefix it covers
+	mid, err := Replay(w, nil, events[:2])
+	require.Nil(t, err)
+	fromSnapshot, err := Replay(w, &mid, events)
+	require.Nil(t, err)
+	require.EqualValues(t, s, fromSnapshot)
+	fromSnapshot, err = Replay(w, &mid, events[2:])
+	require.Nil(t, err)
+	require.EqualValues(t, s, fromSnapshot)
+}
+
+func Test_Replay_rejects_broken_streams(t *testing.T) {
+	w := newEventSourcedWorkflow(t)
+	now := time.Now()
+	ev := func(seq uint64, name types.TxEvent, from, to types.TxState, version uint64) TransitionEvent {
+		return TransitionEvent{Sequence: seq, Kind: TransitionedEventKind, Event: name, From: from, To: to, Version: version, OccurredAt: now}
+	}
+
+	_, err := Replay(w, nil, []TransitionEvent{
+		ev(1, types.PendingTxEvent, types.PendingTxState, types.PendingTxState, 1),
+		ev(3, types.ApproveTxEvent, types.PendingTxState, types.ActiveTxState, 2),
+	})
+	require.ErrorContains(t, err, "event 2 is missing")
+
+	_, err = Replay(w, nil, []TransitionEvent{
+		ev(1, types.PendingTxEvent, types.PendingTxState, types.PendingTxState, 2),
+		ev(2, types.ApproveTxEvent, types.PendingTxState, types.ActiveTxState, 1),
+	})
+	require.ErrorContains(t, err, "goes back")
+
+	_, err = Replay(w, nil, []TransitionEvent{
+		ev(1, types.ApproveTxEvent, types.PendingTxState, types.ActiveTxState, 1),
+		ev(2, types.CancelTxEvent, types.ActiveTxState, types.CanceledTxState, 2),
+	})
+	require.ErrorContains(t, err, "event 2: cancel does not lead from")
+
+	_, err = Replay(w, nil, []TransitionEvent{
+		ev(1, types.ApproveTxEvent, types.PendingTxState, types.ActiveTxState, 1),
+		ev(2, types.ApproveTxEvent, types.PendingTxState, types.ActiveTxState, 2),
+	})
+	require.ErrorContains(t, err, "event 2: starts at \"pending\" but the entity is at \"active\"")
+
+	_, err = Replay(w, nil, []TransitionEvent{
+		{Sequence: 1, Kind: ForcedEventKind, From: types.PendingTxState, To: "archived", Version: 1},
+	})
+	require.ErrorContains(t, err, "state is invalid")
+
+	s, err := Replay(w, nil, []TransitionEvent{
+		{Sequence: 1, Kind: ForcedEventKind, From: types.PendingTxState, To: types.RemovedTxState, Version: 1, OccurredAt: now},
+	})
+	require.Nil(t, err)
+	require.EqualValues(t, types.RemovedTxState, s.State)
+}
+
+func Test_TxStateMachineClock_ReplaySm(t *testing.T) {
+	w := newEventSourcedWorkflow(t)
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.PendingSm())
+	e.ResetTicked()
+	require.Nil(t, e.ApproveSm())
+	stored := e.UncommittedEventsSm()
+
+	restored := &TxStateMachineClock{}
+	require.Nil(t, restored.SetWorkflowSm(w))
+	require.Nil(t, restored.ReplaySm(nil, stored))
+	require.EqualValues(t, types.ActiveTxState, restored.State)
+	require.EqualValues(t, 2, restored.Version)
+	require.Len(t, restored.UncommittedEventsSm(), 0)
+
+	require.Nil(t, restored.InactivePendingSm())
+	next := restored.UncommittedEventsSm()
+	require.Len(t, next, 1)
+	require.EqualValues(t, 3, next[0].Sequence)
+	require.EqualValues(t, 3, next[0].Version)
+
+	s, err := Replay(w, nil, append(stored, next...))
+	require.Nil(t, err)
+	require.EqualValues(t, types.InactivePendingTxState, s.State)
+
+	broken := append([]TransitionEvent(nil), stored...)
+	broken[1].To = types.RemovedTxState
+	require.NotNil(t, restored.ReplaySm(nil, broken))
+	require.EqualValues(t, types.InactivePendingTxState, restored.State)
+}
+
+func Test_SetDefaultWorkflow(t *testing.T) {
+	prev := DefaultWorkflow()
+	defer SetDefaultWorkflow(prev)
+
+	require.NotNil(t, SetDefaultWorkflow(nil))
+	require.Nil(t, SetDefaultWorkflow(newEventSourcedWorkflow(t)))
+
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.PendingSm())
+	require.Len(t, e.UncommittedEventsSm(), 1)
+}
@@ -0,0 +1,75 @@
+package state
+
+import "time"
+
+// TxHybridClock is TxClock ordered by a hybrid logical clock, for entities written by several nodes.
+// HLC orders updates across nodes as long as their clocks are within the tolerated skew,
+// and every node merges the timestamps it receives with Witness.
+type TxHybridClock struct {
+	Version       uint64 `gorm:"column:version;type:uint" json:"version,omitempty"`
+	VersionTicked bool   `gorm:"-:all" json:"-"`
+	HLC           HLC    `gorm:"column:hlc;type:bigint;index:idx_hlc" json:"hlc"`
+
+	CreatedAt *time.Time `gorm:"<-:create;index:idx_created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `gorm:"<-;index:idx_updated_at" json:"updated_at,omitempty"`
+}
+
+// Tick increments Version, sets a timestamp of DefaultHLCSource to HLC,
+// and sets the time of DefaultClock to CreatedAt and UpdatedAt.
+// It returns immediately if Version is already incremented.
+// A Stateful ticks it with the clock and HLCSource of its workflow instead.
+func (e *TxHybridClock) Tick() {
+	e.tickAt(DefaultClock().Now())
+}
+
+func (e *TxHybridClock) tickAt(now time.Time) {
+	e.tickHLC(now, DefaultHLCSource())
+}
+
+// tickHLC ticks at now with a timestamp of hlc.
+func (e *TxHybridClock) tickHLC(now time.Time, hlc *HLCSource) {
+	if e.VersionTicked {
+		return
+	}
+	e.VersionTicked = true
+	e.Version++
+	e.HLC = hlc.Now()
+	if e.CreatedAt == nil {
+		e.CreatedAt = &now
+	}
+	e.UpdatedAt = &now
+}
+
+func (e *TxHybridClock) ResetTicked() {
+	e.VersionTicked = false
+}
+
+// Witness merges HLC into DefaultHLCSource, e.g. after e is received from another node,
+// so that the next Tick on this node orders after it.
+func (e *TxHybridClock) Witness() error {
+	return e.WitnessWith(DefaultHLCSource())
+}
+
+// WitnessWith is Witness merging HLC into s, e.g. the HLCSource of the workflow of the entity.
+func (e *TxHybridClock) WitnessWith(s *HLCSource) error {
+	_, err := s.Update(e.HLC)
+	return err
+}
+
+// Compare orders e and o by HLC. See HLC.Compare.
+func (e *TxHybridClock) Compare(o *TxHybridClock) int {
+	return e.HLC.Compare(o.HLC)
+}
+
+func (e *TxHybridClock) version() uint64 {
+	return e.Version
+}
+
+func (e *TxHybridClock) times() (createdAt, updatedAt *time.Time) {
+	return e.CreatedAt, e.UpdatedAt
+}
+
+func (e *TxHybridClock) restore(version uint64, createdAt, updatedAt *time.Time) {
+	e.Version, e.CreatedAt, e.UpdatedAt = version, createdAt, updatedAt
+	e.VersionTicked = false
+}
@@ -0,0 +1,51 @@
+package state
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// Import is the state and clock of a legacy record given to ImportSm.
+type Import struct {
+	State     types.TxState
+	Version   uint64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ImportSm gives e the state and clock of a legacy record as they are, without ticking.
+// A clock that keeps no version or times, like NoClock, only gets the state.
+// The entity entered r.State at r.UpdatedAt. e must have no state yet and r.State must be declared by its workflow.
+// The import is observed, published and recorded like a transition of kind ImportedEventKind,
+// whose CreatedAt keeps r.CreatedAt for Replay.
+func (e *core[C, PC]) ImportSm(r Import) error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	if r.CreatedAt.IsZero() || r.UpdatedAt.IsZero() {
+		return errors.New("created and updated times are required")
+	}
+	if r.UpdatedAt.Before(r.CreatedAt) {
+		return errors.New("updated time is before created time")
+	}
+
+	return e.apply(e.state, e.self, TransitionOutcome{Kind: ImportedEventKind}, func(sm *internal.TxStateMachine) error {
+		return sm.Init(r.State)
+	}, func(o *TransitionOutcome) TransitionEvent {
+		created, updated := r.CreatedAt, r.UpdatedAt
+		e.restoreClock(r.Version, &created, &updated)
+		if l := e.hostLifecycle(); l != nil {
+			l.enter(*e.state, updated)
+		}
+		if u := e.hostUndo(); u != nil {
+			*u = Undo{}
+		}
+		o.Version = e.version()
+		v := e.event(*o, updated)
+		v.CreatedAt = &created
+		return e.record(v)
+	})
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_TxStateMachineClock_ImportSm(t *testing.T) {
+	created := time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w, err := newEventSourcedWorkflow(t).With(WithClock(NewManualClock(now)))
+	require.Nil(t, err)
+
+	e := newTrackedOrder(t, w)
+	require.Nil(t, e.ImportSm(Import{State: types.ActiveTxState, Version: 7, CreatedAt: created, UpdatedAt: updated}))
+	require.EqualValues(t, types.ActiveTxState, e.State)
+	require.EqualValues(t, 7, e.Version)
+	require.False(t, e.VersionTicked)
+	require.Equal(t, created, *e.CreatedAt)
+	require.Equal(t, updated, *e.UpdatedAt)
+	require.Equal(t, updated, *e.ActivatedAt)
+	require.Equal(t, StateTimes{types.ActiveTxState: updated}, e.EnteredAt)
+
+	events := e.UncommittedEventsSm()
+	require.Equal(t, []TransitionEvent{
+		{Sequence: 1, Kind: ImportedEventKind, To: types.ActiveTxState, Version: 7, OccurredAt: updated, CreatedAt: &created},
+	}, events)
+
+	// the entity goes on from the imported clock
+	require.Nil(t, e.ModifyPendingSm())
+	require.EqualValues(t, 8, e.Version)
+	require.Equal(t, created, *e.CreatedAt)
+	require.Equal(t, now, *e.UpdatedAt)
+
+	s, err := Replay(w, nil, e.UncommittedEventsSm())
+	require.Nil(t, err)
+	require.EqualValues(t, types.ModifyPendingTxState, s.State)
+	require.EqualValues(t, 8, s.Version)
+	require.Equal(t, created, *s.CreatedAt)
+	require.Equal(t, now, *s.UpdatedAt)
+	require.Equal(t, updated, s.EnteredAt[types.ActiveTxState])
+
+	r := &TxStateMachineClock{}
+	require.Nil(t, r.SetWorkflowSm(w))
+	require.Nil(t, r.ReplaySm(nil, e.UncommittedEventsSm()))
+	require.Equal(t, created, *r.CreatedAt)
+}
+
+func Test_TxStateMachineClock_ImportSm_invalid(t *testing.T) {
+	at := time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	var got []TransitionOutcome
+	w, err := DefaultWorkflow().With(WithObserver(TransitionObserverFunc(func(o TransitionOutcome) { got = append(got, o) })))
+	require.Nil(t, err)
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+
+	require.NotNil(t, e.ImportSm(Import{State: types.ActiveTxState}))
+	require.NotNil(t, e.ImportSm(Import{State: types.ActiveTxState, CreatedAt: at, UpdatedAt: at.Add(-time.Hour)}))
+	err = e.ImportSm(Import{State: "unknown", CreatedAt: at, UpdatedAt: at})
+	require.Equal(t, InvalidStateError, ErrorKindOf(err))
+	require.EqualValues(t, "", e.State)
+	require.Nil(t, e.CreatedAt)
+
+	require.Nil(t, e.ImportSm(Import{State: types.InactiveTxState, Version: 2, CreatedAt: at, UpdatedAt: at}))
+	require.NotNil(t, e.ImportSm(Import{State: types.ActiveTxState, Version: 3, CreatedAt: at, UpdatedAt: at}))
+	require.EqualValues(t, types.InactiveTxState, e.State)
+	require.EqualValues(t, 2, e.Version)
+
+	require.Len(t, got, 3)
+	require.Equal(t, ImportedEventKind, got[1].Kind)
+	require.Equal(t, SucceededResult, got[1].Result)
+	require.EqualValues(t, 2, got[1].Version)
+	require.Equal(t, FailedResult, got[2].Result)
+}
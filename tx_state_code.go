@@ -0,0 +1,90 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wonksing/state/types"
+)
+
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// TxStateCodeMigrationSQL returns statements that add codeColumn to table and fill it from stateColumn.
+// Rows whose state has no registered code get types.UnknownTxStateCode.
+// The string column is left untouched so it can be dropped once every reader uses the code.
+func TxStateCodeMigrationSQL(table, stateColumn, codeColumn string) ([]string, error) {
+	for _, v := range []string{table, stateColumn, codeColumn} {
+		if !sqlIdentifierPattern.MatchString(v) {
+			return nil, fmt.Errorf("invalid sql identifier: %q", v)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "UPDATE %s SET %s = CASE %s", table, codeColumn, stateColumn)
+	for _, c := range types.TxStateCodes() {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", strings.ReplaceAll(string(c.State()), "'", "''"), c)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", types.UnknownTxStateCode)
+
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s SMALLINT NOT NULL DEFAULT %d", table, codeColumn, types.UnknownTxStateCode),
+		b.String(),
+	}, nil
+}
+
+// MigrateTxStateCode adds codeColumn to table, then fills it from stateColumn like BackfillTxStateCode.
+// The column is added before the transaction of the backfill begins, because MySQL and Oracle commit ALTER TABLE
+// implicitly. So on every database, a failed backfill is rolled back but the column stays, filled with
+// types.UnknownTxStateCode. Fix the offending rows and run BackfillTxStateCode again in that case.
+func MigrateTxStateCode(ctx context.Context, db *sql.DB, table, stateColumn, codeColumn string) error {
+	stmts, err := TxStateCodeMigrationSQL(table, stateColumn, codeColumn)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, stmts[0]); err != nil {
+		return err
+	}
+	return backfillTxStateCode(ctx, db, table, stateColumn, codeColumn, stmts[1])
+}
+
+// BackfillTxStateCode fills the existing codeColumn of table from stateColumn in a single transaction.
+// It fails and rolls back if any non-empty state could not be converted.
+func BackfillTxStateCode(ctx context.Context, db *sql.DB, table, stateColumn, codeColumn string) error {
+	stmts, err := TxStateCodeMigrationSQL(table, stateColumn, codeColumn)
+	if err != nil {
+		return err
+	}
+	return backfillTxStateCode(ctx, db, table, stateColumn, codeColumn, stmts[1])
+}
+
+func backfillTxStateCode(ctx context.Context, db *sql.DB, table, stateColumn, codeColumn, update string) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, update); err != nil {
+		return err
+	}
+
+	var unknown int64
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = %d AND %s <> ''",
+		table, codeColumn, types.UnknownTxStateCode, stateColumn)).Scan(&unknown)
+	if err != nil {
+		return err
+	}
+	if unknown > 0 {
+		err = fmt.Errorf("%d rows of %s have states without a code", unknown, table)
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_TxStateCodeMigrationSQL(t *testing.T) {
+	_, err := TxStateCodeMigrationSQL("orders; drop table x", "state", "state_code")
+	require.NotNil(t, err)
+
+	stmts, err := TxStateCodeMigrationSQL("orders", "state", "state_code")
+	require.Nil(t, err)
+	require.Len(t, stmts, 2)
+	require.EqualValues(t, "ALTER TABLE orders ADD COLUMN state_code SMALLINT NOT NULL DEFAULT 0", stmts[0])
+	require.Contains(t, stmts[1], "WHEN 'pending' THEN 1")
+	require.Contains(t, stmts[1], "WHEN 'active_pending' THEN 9")
+}
+
+func Test_MigrateTxStateCode(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, state VARCHAR(32) NOT NULL DEFAULT '')")
+	require.Nil(t, err)
+	_, err = db.Exec("INSERT INTO orders (state) VALUES ('pending'), ('active'), ('removed'), ('')")
+	require.Nil(t, err)
+
+	err = MigrateTxStateCode(ctx, db, "orders", "state", "state_code")
+	require.Nil(t, err)
+
+	rows, err := db.Query("SELECT state, state_code FROM orders ORDER BY id")
+	require.Nil(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var s types.TxState
+		var c types.TxStateCode
+		require.Nil(t, rows.Scan(&s, &c))
+		require.EqualValues(t, s.Code(), c)
+	}
+	require.Nil(t, rows.Err())
+}
+
+func Test_MigrateTxStateCode_unknown_state(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, state VARCHAR(32) NOT NULL DEFAULT '')")
+	require.Nil(t, err)
+	_, err = db.Exec("INSERT INTO orders (state) VALUES ('active'), ('archived')")
+	require.Nil(t, err)
+
+	err = MigrateTxStateCode(ctx, db, "orders", "state", "state_code")
+	require.NotNil(t, err)
+
+	// the column stays but the backfill is rolled back
+	var filled int
+	require.Nil(t, db.QueryRow("SELECT COUNT(*) FROM orders WHERE state_code <> 0").Scan(&filled))
+	require.Equal(t, 0, filled)
+
+	_, err = db.Exec("UPDATE orders SET state = 'inactive' WHERE state = 'archived'")
+	require.Nil(t, err)
+	require.Nil(t, BackfillTxStateCode(ctx, db, "orders", "state", "state_code"))
+	require.Nil(t, db.QueryRow("SELECT COUNT(*) FROM orders WHERE state_code <> 0").Scan(&filled))
+	require.Equal(t, 2, filled)
+}
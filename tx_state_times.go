@@ -0,0 +1,157 @@
+package state
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wonksing/state/types"
+)
+
+// StateTimes maps states to the last time an entity entered them.
+type StateTimes map[types.TxState]time.Time
+
+func (m StateTimes) Clone() StateTimes {
+	if m == nil {
+		return nil
+	}
+	res := make(StateTimes, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// Value implements driver.Valuer. m is stored as a JSON object.
+func (m StateTimes) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[types.TxState]time.Time(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *StateTimes) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan %T into StateTimes", value)
+	}
+	var res map[types.TxState]time.Time
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	*m = res
+	return nil
+}
+
+// Lifecycle records when an entity entered its states. It is optional: embed it in the host of a state machine,
+// next to TxStateMachineClock for example, and give the host to SetHostSm. Every transition then fills it in,
+// while entities that do not embed it keep their columns.
+type Lifecycle struct {
+	// ActivatedAt, CanceledAt, RemovedAt and InactivatedAt are the last time the entity entered
+	// the state of the same name, and EnteredAt the last time it entered any state.
+	// They are set to the time of the transition entering the state.
+	ActivatedAt   *time.Time `gorm:"<-" json:"activated_at,omitempty"`
+	CanceledAt    *time.Time `gorm:"<-" json:"canceled_at,omitempty"`
+	RemovedAt     *time.Time `gorm:"<-" json:"removed_at,omitempty"`
+	InactivatedAt *time.Time `gorm:"<-" json:"inactivated_at,omitempty"`
+	EnteredAt     StateTimes `gorm:"<-;type:text" json:"entered_at,omitempty"`
+}
+
+func (l *Lifecycle) lifecycle() *Lifecycle {
+	return l
+}
+
+// enter records that the entity entered s at at.
+func (l *Lifecycle) enter(s types.TxState, at time.Time) {
+	if l.EnteredAt == nil {
+		l.EnteredAt = make(StateTimes)
+	}
+	l.EnteredAt[s] = at
+	l.setField(s, &at)
+}
+
+// enteredAt returns when the entity last entered s, or nil.
+func (l *Lifecycle) enteredAt(s types.TxState) *time.Time {
+	if at, ok := l.EnteredAt[s]; ok {
+		return &at
+	}
+	return nil
+}
+
+// restore sets when the entity last entered s back to at, forgetting s if at is nil.
+func (l *Lifecycle) restore(s types.TxState, at *time.Time) {
+	if at == nil {
+		delete(l.EnteredAt, s)
+		l.setField(s, nil)
+		return
+	}
+	v := *at
+	l.EnteredAt[s] = v
+	l.setField(s, &v)
+}
+
+// reset sets l to the times of entered.
+func (l *Lifecycle) reset(entered StateTimes) {
+	*l = Lifecycle{EnteredAt: entered.Clone()}
+	for k, v := range entered {
+		v := v
+		l.setField(k, &v)
+	}
+}
+
+// clone returns a copy of l that does not share EnteredAt.
+func (l *Lifecycle) clone() Lifecycle {
+	res := *l
+	res.EnteredAt = l.EnteredAt.Clone()
+	return res
+}
+
+// setField sets the field named after s to at, if there is one.
+func (l *Lifecycle) setField(s types.TxState, at *time.Time) {
+	switch s {
+	case types.ActiveTxState:
+		l.ActivatedAt = at
+	case types.CanceledTxState:
+		l.CanceledAt = at
+	case types.RemovedTxState:
+		l.RemovedAt = at
+	case types.InactiveTxState:
+		l.InactivatedAt = at
+	}
+}
+
+// hostLifecycle returns the Lifecycle embedded by the host of e, or nil.
+func (e *core[C, PC]) hostLifecycle() *Lifecycle {
+	if h, ok := e.host.(interface{ lifecycle() *Lifecycle }); ok {
+		return h.lifecycle()
+	}
+	return nil
+}
+
+// enteredAt returns when e entered its state. Without a Lifecycle, or for entities saved before it was recorded,
+// it falls back to the UpdatedAt of their clock, their last change, which is no earlier than when they entered their state.
+func (e *core[C, PC]) enteredAt() (time.Time, bool) {
+	if l := e.hostLifecycle(); l != nil {
+		if at, ok := l.EnteredAt[*e.state]; ok {
+			return at, true
+		}
+	}
+	if _, at := e.times(); *e.state != "" && at != nil {
+		return *at, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,126 @@
+package state
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+	"gorm.io/gorm/schema"
+)
+
+// trackedOrder is a host keeping the optional parts of its state machine.
+type trackedOrder struct {
+	ID string
+	TxStateMachineClock
+	Lifecycle
+	Assignment
+	Undo
+	EventSequence
+}
+
+func (o *trackedOrder) EntityID() string {
+	return o.ID
+}
+
+func newTrackedOrder(t *testing.T, w *Workflow) *trackedOrder {
+	o := &trackedOrder{ID: "o-1"}
+	require.Nil(t, o.SetWorkflowSm(w))
+	o.SetHostSm(o)
+	return o
+}
+
+func Test_Lifecycle(t *testing.T) {
+	c := NewManualClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	w, err := DefaultWorkflow().With(WithClock(c), WithEventSourcing(), WithUnrestrictedForce())
+	require.Nil(t, err)
+	e := newTrackedOrder(t, w)
+
+	created := c.Now()
+	require.Nil(t, e.PendingSm())
+	require.Equal(t, StateTimes{types.PendingTxState: created}, e.EnteredAt)
+	require.Nil(t, e.ActivatedAt)
+
+	e.ResetTicked()
+	approved := c.Advance(time.Hour)
+	require.Nil(t, e.ApproveSm())
+	require.Equal(t, approved, *e.ActivatedAt)
+
+	// requesting modify_pending twice enters it once
+	e.ResetTicked()
+	modified := c.Advance(time.Hour)
+	require.Nil(t, e.ModifyPendingSm())
+	e.ResetTicked()
+	c.Advance(time.Hour)
+	require.Nil(t, e.ModifyPendingSm())
+	require.Equal(t, modified, e.EnteredAt[types.ModifyPendingTxState])
+
+	e.ResetTicked()
+	removed := c.Advance(time.Hour)
+	require.Nil(t, e.ForceStateSm(types.RemovedTxState))
+	require.Equal(t, removed, *e.RemovedAt)
+	require.Equal(t, approved, *e.ActivatedAt)
+	require.Nil(t, e.CanceledAt)
+	require.Nil(t, e.InactivatedAt)
+	require.Len(t, e.EnteredAt, 4)
+
+	// replaying the events yields the same times
+	r := newTrackedOrder(t, w)
+	require.Nil(t, r.ReplaySm(nil, e.UncommittedEventsSm()))
+	require.Equal(t, e.EnteredAt, r.EnteredAt)
+	require.Equal(t, removed, *r.RemovedAt)
+	require.Equal(t, approved, *r.ActivatedAt)
+	require.Equal(t, e.EnteredAt, r.SnapshotSm().EnteredAt)
+}
+
+func Test_StateTimes_sql(t *testing.T) {
+	m := StateTimes{types.ActiveTxState: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	v, err := m.Value()
+	require.Nil(t, err)
+	require.Equal(t, `{"active":"2024-03-01T00:00:00Z"}`, v)
+
+	var got StateTimes
+	require.Nil(t, got.Scan(v))
+	require.Equal(t, m, got)
+	require.Nil(t, got.Scan(nil))
+	require.Nil(t, got)
+}
+
+func Test_Lifecycle_takes_the_time_of_the_change(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(t0)
+	w, err := DefaultWorkflow().With(WithClock(c), WithEventSourcing())
+	require.Nil(t, err)
+	e := newTrackedOrder(t, w)
+
+	// the clock is not reset, so UpdatedAt stays at the first change
+	require.Nil(t, e.PendingSm())
+	approved := c.Advance(time.Hour)
+	require.Nil(t, e.ApproveSm())
+	require.Equal(t, t0, *e.UpdatedAt)
+	require.Equal(t, approved, *e.ActivatedAt)
+	require.Equal(t, approved, e.UncommittedEventsSm()[1].OccurredAt)
+	require.Equal(t, time.Hour, e.TimeInCurrentStateSm(approved.Add(time.Hour)))
+
+	s, err := Replay(w, nil, e.UncommittedEventsSm())
+	require.Nil(t, err)
+	require.Equal(t, e.SnapshotSm(), s)
+}
+
+func Test_Lifecycle_is_optional(t *testing.T) {
+	columns := func(v any) []string {
+		s, err := schema.Parse(v, &sync.Map{}, schema.NamingStrategy{})
+		require.Nil(t, err)
+		return s.DBNames
+	}
+	require.Equal(t, []string{"state"}, columns(&TxStateMachine{}))
+	require.Equal(t, []string{"state", "version", "created_at", "updated_at"}, columns(&TxStateMachineClock{}))
+	require.Contains(t, columns(&trackedOrder{}), "entered_at")
+
+	// without a Lifecycle, nothing is recorded and the state is timed from UpdatedAt
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.PendingSm())
+	require.Empty(t, e.SnapshotSm().EnteredAt)
+	require.Equal(t, time.Hour, e.TimeInCurrentStateSm(e.UpdatedAt.Add(time.Hour)))
+}
@@ -1,15 +1,23 @@
 package state
 
 import (
-	"errors"
+	"time"
 
-	"github.com/wonksing/state/internal"
 	"github.com/wonksing/state/types"
 )
 
+// TxStateMachine is a state machine without a clock. Embed it in an entity to give it a state.
+// It has the methods of Stateful with NoClock, but only the state column.
 type TxStateMachine struct {
-	State        types.TxState            `gorm:"column:state;type:string;size:32;comment:state" json:"state,omitempty"`
-	stateMachine *internal.TxStateMachine `gorm:"-:all" json:"-"`
+	State types.TxState `gorm:"column:state;type:string;size:32;comment:state" json:"state,omitempty"`
+	machine
+}
+
+func (e *TxStateMachine) sm() *core[NoClock, *NoClock] {
+	if e == nil {
+		return nil
+	}
+	return &core[NoClock, *NoClock]{machine: &e.machine, state: &e.State, clock: &NoClock{}, self: e}
 }
 
 // AssignStateCallback sets newState to underlying State. It implements internal.TxStateAssignor interface.
@@ -19,200 +27,170 @@ func (e *TxStateMachine) AssignStateCallback(newState types.TxState) error {
 	return nil
 }
 
+func (e *TxStateMachine) InitSm(s types.TxState) error {
+	return e.sm().InitSm(s)
+}
+
+func (e *TxStateMachine) ForceStateSm(newState types.TxState) error {
+	return e.sm().ForceStateSm(newState)
+}
+
+func (e *TxStateMachine) OverrideSm(r Override) error {
+	return e.sm().OverrideSm(r)
+}
+
+func (e *TxStateMachine) PendingSm() error {
+	return e.sm().PendingSm()
+}
+
+func (e *TxStateMachine) ModifyPendingSm() error {
+	return e.sm().ModifyPendingSm()
+}
+
+func (e *TxStateMachine) RemovePendingSm() error {
+	return e.sm().RemovePendingSm()
+}
+
+func (e *TxStateMachine) InactivePendingSm() error {
+	return e.sm().InactivePendingSm()
+}
+
+func (e *TxStateMachine) ActivePendingSm() error {
+	return e.sm().ActivePendingSm()
+}
+
+func (e *TxStateMachine) ApproveSm() error {
+	return e.sm().ApproveSm()
+}
+
+func (e *TxStateMachine) CancelSm() error {
+	return e.sm().CancelSm()
+}
+
+func (e *TxStateMachine) FireSm(ev types.TxEvent) error {
+	return e.sm().FireSm(ev)
+}
+
+func (e *TxStateMachine) UndoSm() error {
+	return e.sm().UndoSm()
+}
+
+func (e *TxStateMachine) AssignSm(assignee, reason string) error {
+	return e.sm().AssignSm(assignee, reason)
+}
+
+func (e *TxStateMachine) ImportSm(r Import) error {
+	return e.sm().ImportSm(r)
+}
+
 func (e *TxStateMachine) EqualSm(s types.TxState) bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.Equal(s)
+	return e.sm().EqualSm(s)
 }
 
 func (e *TxStateMachine) IsPendingKindSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsPendingKind()
+	return e.sm().IsPendingKindSm()
 }
 
 func (e *TxStateMachine) IsPendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsPending()
+	return e.sm().IsPendingSm()
 }
 
 func (e *TxStateMachine) IsModifyPendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsModifyPending()
+	return e.sm().IsModifyPendingSm()
 }
 
 func (e *TxStateMachine) IsRemovePendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsRemovePending()
+	return e.sm().IsRemovePendingSm()
 }
 
 func (e *TxStateMachine) IsActiveSm() bool {
-	if e == nil {
-		return false
-	}
-
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsActive()
+	return e.sm().IsActiveSm()
 }
 
 func (e *TxStateMachine) IsCanceledSm() bool {
-	if e == nil {
-		return false
-	}
-
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsCanceled()
+	return e.sm().IsCanceledSm()
 }
 
 func (e *TxStateMachine) IsRemovedSm() bool {
-	if e == nil {
-		return false
-	}
+	return e.sm().IsRemovedSm()
+}
 
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsRemoved()
+func (e *TxStateMachine) IsCategorySm(c types.TxCategory) bool {
+	return e.sm().IsCategorySm(c)
 }
 
-// func (e *TxStateMachine) SetStateSm(newState types.TxState) error {
-// 	if e == nil {
-// 		return errors.New("not initialized")
-// 	}
-// 	if err := e.checkAndInitStateMachine(); err != nil {
-// 		return err
-// 	}
-// 	return e.stateMachine.SetState(newState)
-// }
+func (e *TxStateMachine) SetHostSm(host any) {
+	e.sm().SetHostSm(host)
+}
 
-func (e *TxStateMachine) ForceStateSm(newState types.TxState) error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	return e.stateMachine.ForceState(newState)
+func (e *TxStateMachine) SetActorSm(actor string) {
+	e.sm().SetActorSm(actor)
 }
 
-func (e *TxStateMachine) PendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.PendingTxState); err != nil {
-		return err
-	}
-	return e.stateMachine.SetState(types.PendingTxState)
+func (e *TxStateMachine) ActorSm() string {
+	return e.sm().ActorSm()
 }
 
-func (e *TxStateMachine) ModifyPendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.ModifyPendingTxState); err != nil {
-		return err
-	}
-	return e.stateMachine.SetState(types.ModifyPendingTxState)
+func (e *TxStateMachine) SetWorkflowSm(w *Workflow) error {
+	return e.sm().SetWorkflowSm(w)
 }
 
-func (e *TxStateMachine) RemovePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.RemovePendingTxState); err != nil {
-		return err
-	}
-	return e.stateMachine.SetState(types.RemovePendingTxState)
+func (e *TxStateMachine) WorkflowSm() *Workflow {
+	return e.sm().WorkflowSm()
 }
 
-func (e *TxStateMachine) InactivePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.InactivePendingTxState); err != nil {
-		return err
-	}
-	return e.stateMachine.SetState(types.InactivePendingTxState)
+func (e *TxStateMachine) Tick() {
+	e.sm().Tick()
 }
 
-func (e *TxStateMachine) ActivePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.ActivePendingTxState); err != nil {
-		return err
-	}
-	return e.stateMachine.SetState(types.ActivePendingTxState)
+func (e *TxStateMachine) ResetTicked() {
+	e.sm().ResetTicked()
 }
-func (e *TxStateMachine) ApproveSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	return e.stateMachine.Approve()
+
+func (e *TxStateMachine) BeginUnit() {
+	e.sm().BeginUnit()
 }
 
-func (e *TxStateMachine) CancelSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	return e.stateMachine.Cancel()
+func (e *TxStateMachine) CommitUnit() {
+	e.sm().CommitUnit()
 }
 
-// checkAndInitStateMachine check and initialize e.stateMachine.
-// It initializes e.stateMachine with PendingTxState if e.State is empty.
-func (e *TxStateMachine) checkAndInitStateMachine() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if e.State == "" {
-		e.State = types.PendingTxState
-	}
-	return e.checkAndInitStateMachineWithState(e.State)
+func (e *TxStateMachine) RollbackUnit() {
+	e.sm().RollbackUnit()
 }
-func (e *TxStateMachine) checkAndInitStateMachineWithState(s types.TxState) error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
 
-	if e.stateMachine == nil {
-		var err error
-		e.stateMachine, err = internal.NewTxStateMachine(s, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+func (e *TxStateMachine) UncommittedEventsSm() []TransitionEvent {
+	return e.sm().UncommittedEventsSm()
+}
+
+func (e *TxStateMachine) ClearEventsSm() {
+	e.sm().ClearEventsSm()
+}
+
+func (e *TxStateMachine) SnapshotSm() Snapshot {
+	return e.sm().SnapshotSm()
+}
+
+func (e *TxStateMachine) ReplaySm(snapshot *Snapshot, events []TransitionEvent) error {
+	return e.sm().ReplaySm(snapshot, events)
+}
+
+func (e *TxStateMachine) EscalateSm(now time.Time, ds Delegations) (Escalation, bool, error) {
+	return e.sm().EscalateSm(now, ds)
+}
+
+func (e *TxStateMachine) TimeInCurrentStateSm(now time.Time) time.Duration {
+	return e.sm().TimeInCurrentStateSm(now)
+}
+
+func (e *TxStateMachine) SLAStatusSm(now time.Time) SLAStatus {
+	return e.sm().SLAStatusSm(now)
+}
+
+func (e *TxStateMachine) SLAReportSm(now time.Time) SLAReport {
+	return e.sm().SLAReportSm(now)
+}
+
+func (e *TxStateMachine) SLADeadlineSm() (time.Time, bool) {
+	return e.sm().SLADeadlineSm()
 }
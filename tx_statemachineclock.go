@@ -1,22 +1,25 @@
 package state
 
 import (
-	"errors"
 	"time"
 
-	"github.com/wonksing/state/internal"
 	"github.com/wonksing/state/types"
 )
 
+// TxStateMachineClock is a state machine with a TxClock, ticked by every transition.
+// Embed it in an entity to give it a state and a version.
+// It has the methods of Stateful with TxClock, whose fields are promoted.
 type TxStateMachineClock struct {
-	State        types.TxState            `gorm:"column:state;type:string;size:32;comment:state" json:"state,omitempty"`
-	stateMachine *internal.TxStateMachine `gorm:"-:all" json:"-"`
-
-	Version       uint64 `gorm:"column:version;type:uint" json:"version,omitempty"`
-	VersionTicked bool   `gorm:"-:all" json:"-"`
+	State types.TxState `gorm:"column:state;type:string;size:32;comment:state" json:"state,omitempty"`
+	machine
+	TxClock
+}
 
-	CreatedAt *time.Time `gorm:"<-:create;index:idx_created_at" json:"created_at,omitempty"`
-	UpdatedAt *time.Time `gorm:"<-;index:idx_updated_at" json:"updated_at,omitempty"`
+func (e *TxStateMachineClock) sm() *core[TxClock, *TxClock] {
+	if e == nil {
+		return nil
+	}
+	return &core[TxClock, *TxClock]{machine: &e.machine, state: &e.State, clock: &e.TxClock, self: e}
 }
 
 // AssignStateCallback sets newState to underlying State. It implements internal.TxStateAssignor interface.
@@ -26,268 +29,170 @@ func (e *TxStateMachineClock) AssignStateCallback(newState types.TxState) error
 	return nil
 }
 
+func (e *TxStateMachineClock) InitSm(s types.TxState) error {
+	return e.sm().InitSm(s)
+}
+
+func (e *TxStateMachineClock) ForceStateSm(newState types.TxState) error {
+	return e.sm().ForceStateSm(newState)
+}
+
+func (e *TxStateMachineClock) OverrideSm(r Override) error {
+	return e.sm().OverrideSm(r)
+}
+
+func (e *TxStateMachineClock) PendingSm() error {
+	return e.sm().PendingSm()
+}
+
+func (e *TxStateMachineClock) ModifyPendingSm() error {
+	return e.sm().ModifyPendingSm()
+}
+
+func (e *TxStateMachineClock) RemovePendingSm() error {
+	return e.sm().RemovePendingSm()
+}
+
+func (e *TxStateMachineClock) InactivePendingSm() error {
+	return e.sm().InactivePendingSm()
+}
+
+func (e *TxStateMachineClock) ActivePendingSm() error {
+	return e.sm().ActivePendingSm()
+}
+
+func (e *TxStateMachineClock) ApproveSm() error {
+	return e.sm().ApproveSm()
+}
+
+func (e *TxStateMachineClock) CancelSm() error {
+	return e.sm().CancelSm()
+}
+
+func (e *TxStateMachineClock) FireSm(ev types.TxEvent) error {
+	return e.sm().FireSm(ev)
+}
+
+func (e *TxStateMachineClock) UndoSm() error {
+	return e.sm().UndoSm()
+}
+
+func (e *TxStateMachineClock) AssignSm(assignee, reason string) error {
+	return e.sm().AssignSm(assignee, reason)
+}
+
+func (e *TxStateMachineClock) ImportSm(r Import) error {
+	return e.sm().ImportSm(r)
+}
+
 func (e *TxStateMachineClock) EqualSm(s types.TxState) bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.Equal(s)
+	return e.sm().EqualSm(s)
 }
 
 func (e *TxStateMachineClock) IsPendingKindSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsPendingKind()
+	return e.sm().IsPendingKindSm()
 }
 
 func (e *TxStateMachineClock) IsPendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsPending()
+	return e.sm().IsPendingSm()
 }
 
 func (e *TxStateMachineClock) IsModifyPendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsModifyPending()
+	return e.sm().IsModifyPendingSm()
 }
 
 func (e *TxStateMachineClock) IsRemovePendingSm() bool {
-	if e == nil {
-		return false
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsRemovePending()
+	return e.sm().IsRemovePendingSm()
 }
 
 func (e *TxStateMachineClock) IsActiveSm() bool {
-	if e == nil {
-		return false
-	}
-
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsActive()
+	return e.sm().IsActiveSm()
 }
 
 func (e *TxStateMachineClock) IsCanceledSm() bool {
-	if e == nil {
-		return false
-	}
-
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsCanceled()
+	return e.sm().IsCanceledSm()
 }
 
 func (e *TxStateMachineClock) IsRemovedSm() bool {
-	if e == nil {
-		return false
-	}
+	return e.sm().IsRemovedSm()
+}
 
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return false
-	}
-	return e.stateMachine.IsRemoved()
-}
-
-// func (e *StateClock) SetStateSm(newState types.TxState) error {
-// 	if e == nil {
-// 		return errors.New("not initialized")
-// 	}
-// 	err := e.checkAndInitStateMachine()
-// 	if err != nil {
-// 		return err
-// 	}
-// 	err = e.stateMachine.SetState(newState)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	e.Tick()
-// 	return nil
-// }
+func (e *TxStateMachineClock) IsCategorySm(c types.TxCategory) bool {
+	return e.sm().IsCategorySm(c)
+}
 
-func (e *TxStateMachineClock) ForceStateSm(newState types.TxState) error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	err := e.stateMachine.ForceState(newState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) SetHostSm(host any) {
+	e.sm().SetHostSm(host)
 }
 
-func (e *TxStateMachineClock) PendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.PendingTxState); err != nil {
-		return err
-	}
-	err := e.stateMachine.SetState(types.PendingTxState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) SetActorSm(actor string) {
+	e.sm().SetActorSm(actor)
 }
 
-func (e *TxStateMachineClock) ModifyPendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.ModifyPendingTxState); err != nil {
-		return err
-	}
-	err := e.stateMachine.SetState(types.ModifyPendingTxState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) ActorSm() string {
+	return e.sm().ActorSm()
 }
 
-func (e *TxStateMachineClock) RemovePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.RemovePendingTxState); err != nil {
-		return err
-	}
-	err := e.stateMachine.SetState(types.RemovePendingTxState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) SetWorkflowSm(w *Workflow) error {
+	return e.sm().SetWorkflowSm(w)
 }
 
-func (e *TxStateMachineClock) InactivePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.InactivePendingTxState); err != nil {
-		return err
-	}
-	err := e.stateMachine.SetState(types.InactivePendingTxState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) WorkflowSm() *Workflow {
+	return e.sm().WorkflowSm()
 }
 
-func (e *TxStateMachineClock) ActivePendingSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachineWithState(types.ActivePendingTxState); err != nil {
-		return err
-	}
-	err := e.stateMachine.SetState(types.ActivePendingTxState)
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) Tick() {
+	e.sm().Tick()
 }
 
-func (e *TxStateMachineClock) ApproveSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	err := e.stateMachine.Approve()
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) ResetTicked() {
+	e.sm().ResetTicked()
 }
 
-func (e *TxStateMachineClock) CancelSm() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if err := e.checkAndInitStateMachine(); err != nil {
-		return err
-	}
-	err := e.stateMachine.Cancel()
-	if err != nil {
-		return err
-	}
-	e.Tick()
-	return nil
+func (e *TxStateMachineClock) BeginUnit() {
+	e.sm().BeginUnit()
 }
 
-// checkAndInitStateMachine check and initialize e.stateMachine.
-// It initializes e.stateMachine with PendingTxState if e.State is empty.
-func (e *TxStateMachineClock) checkAndInitStateMachine() error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
-	if e.State == "" {
-		e.State = types.PendingTxState
-	}
-	return e.checkAndInitStateMachineWithState(e.State)
+func (e *TxStateMachineClock) CommitUnit() {
+	e.sm().CommitUnit()
 }
-func (e *TxStateMachineClock) checkAndInitStateMachineWithState(s types.TxState) error {
-	if e == nil {
-		return errors.New("not initialized")
-	}
 
-	if e.stateMachine == nil {
-		var err error
-		e.stateMachine, err = internal.NewTxStateMachine(s, e)
-		if err != nil {
-			return err
-		}
-	}
+func (e *TxStateMachineClock) RollbackUnit() {
+	e.sm().RollbackUnit()
+}
 
-	return nil
+func (e *TxStateMachineClock) UncommittedEventsSm() []TransitionEvent {
+	return e.sm().UncommittedEventsSm()
 }
 
-// Tick increments Version and set current time to CreatedAt and UpdatedAt.
-// It returns immediately if Version is already incremented.
-func (e *TxStateMachineClock) Tick() {
-	if e.VersionTicked {
-		return
-	}
-	e.VersionTicked = true
-	e.Version++
+func (e *TxStateMachineClock) ClearEventsSm() {
+	e.sm().ClearEventsSm()
+}
 
-	now := time.Now()
-	if e.CreatedAt == nil {
-		e.CreatedAt = &now
-	}
-	e.UpdatedAt = &now
+func (e *TxStateMachineClock) SnapshotSm() Snapshot {
+	return e.sm().SnapshotSm()
 }
 
-func (e *TxStateMachineClock) ResetTicked() {
-	e.VersionTicked = false
+func (e *TxStateMachineClock) ReplaySm(snapshot *Snapshot, events []TransitionEvent) error {
+	return e.sm().ReplaySm(snapshot, events)
+}
+
+func (e *TxStateMachineClock) EscalateSm(now time.Time, ds Delegations) (Escalation, bool, error) {
+	return e.sm().EscalateSm(now, ds)
+}
+
+func (e *TxStateMachineClock) TimeInCurrentStateSm(now time.Time) time.Duration {
+	return e.sm().TimeInCurrentStateSm(now)
+}
+
+func (e *TxStateMachineClock) SLAStatusSm(now time.Time) SLAStatus {
+	return e.sm().SLAStatusSm(now)
+}
+
+func (e *TxStateMachineClock) SLAReportSm(now time.Time) SLAReport {
+	return e.sm().SLAReportSm(now)
+}
+
+func (e *TxStateMachineClock) SLADeadlineSm() (time.Time, bool) {
+	return e.sm().SLADeadlineSm()
 }
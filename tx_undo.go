@@ -0,0 +1,92 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+// ErrUndoExpired is wrapped by the error of UndoSm once the undo window of the workflow has closed.
+var ErrUndoExpired = errors.New("undo window is closed")
+
+// ErrNothingToUndo is wrapped by the error of UndoSm if the last change of the entity is not an approval.
+var ErrNothingToUndo = errors.New("no approval to undo")
+
+// Undo is what UndoSm needs to revert an approval. It is optional: embed it in the host of a state machine
+// and give the host to SetHostSm. It is set by an approval and cleared by any other change.
+type Undo struct {
+	// UndoState is the pending state UndoSm restores.
+	UndoState types.TxState `gorm:"<-;size:32" json:"undo_state,omitempty"`
+	// ApprovedAt is when the approval was made, which opens the undo window.
+	ApprovedAt *time.Time `gorm:"<-" json:"approved_at,omitempty"`
+	// ReplacedAt is when the entity last entered the approved state before the approval, if it did.
+	// UndoSm restores it in Lifecycle, so that the undone approval leaves no times behind.
+	ReplacedAt *time.Time `gorm:"<-" json:"replaced_at,omitempty"`
+}
+
+func (u *Undo) undo() *Undo {
+	return u
+}
+
+// hostUndo returns the Undo embedded by the host of e, or nil.
+func (e *core[C, PC]) hostUndo() *Undo {
+	if h, ok := e.host.(interface{ undo() *Undo }); ok {
+		return h.undo()
+	}
+	return nil
+}
+
+// WithUndoWindow allows UndoSm for d after an approval. Without it, UndoSm fails with ErrForbidden.
+func WithUndoWindow(d time.Duration) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.undoWindow = d
+	}
+}
+
+// UndoWindow returns how long an approval can be undone, zero if it cannot.
+func (w *Workflow) UndoWindow() time.Duration {
+	return w.opts.undoWindow
+}
+
+// undoState returns the state UndoSm restores after a change, which is the pending state
+// an approval left, or empty.
+func (w *Workflow) undoState(kind TransitionEventKind, ev types.TxEvent, from types.TxState) types.TxState {
+	if kind != TransitionedEventKind || ev != types.ApproveTxEvent {
+		return ""
+	}
+	if w.isPending(from) {
+		return from
+	}
+	return ""
+}
+
+// UndoSm restores the pending state e was approved from, if the approval is its last change
+// and happened within the undo window of its workflow. It applies to terminal states alike.
+// It ticks like a transition and records a change of kind UndoneEventKind. The times the approval recorded
+// in Lifecycle are restored. It fails if the host of e does not embed Undo.
+// It is allowed by the permissions of the workflow to whoever may approve from the restored state.
+func (e *core[C, PC]) UndoSm() error {
+	if e == nil {
+		return errors.New("not initialized")
+	}
+	return e.change(TransitionOutcome{Kind: UndoneEventKind}, func(m *internal.TxStateMachine) error {
+		w := e.WorkflowSm()
+		if w.UndoWindow() <= 0 {
+			return fmt.Errorf("%w: undo is disabled in %s", ErrForbidden, w.Name())
+		}
+		u := e.hostUndo()
+		if u == nil {
+			return errors.New("host does not embed Undo, see SetHostSm")
+		}
+		if u.UndoState == "" {
+			return fmt.Errorf("%w: %s was not reached by an approval", ErrNothingToUndo, m.State)
+		}
+		if u.ApprovedAt == nil || w.Clock().Now().Sub(*u.ApprovedAt) > w.UndoWindow() {
+			return fmt.Errorf("%w: %s was approved more than %s ago", ErrUndoExpired, m.State, w.UndoWindow())
+		}
+		return m.ForceState(u.UndoState)
+	})
+}
@@ -0,0 +1,135 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func newUndoEntity(t *testing.T, c Clock, s types.TxState) *trackedOrder {
+	w, err := newEventSourcedWorkflow(t).With(WithClock(c), WithUndoWindow(10*time.Minute))
+	require.Nil(t, err)
+	e := newTrackedOrder(t, w)
+	require.Nil(t, e.InitSm(s))
+	e.ResetTicked()
+	return e
+}
+
+func Test_TxStateMachineClock_UndoSm(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	c := NewManualClock(t0)
+	e := newUndoEntity(t, c, types.PendingTxState)
+
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.EqualValues(t, types.PendingTxState, e.UndoState)
+	c.Advance(5 * time.Minute)
+
+	require.Nil(t, e.UndoSm())
+	e.ResetTicked()
+	require.EqualValues(t, types.PendingTxState, e.State)
+	require.EqualValues(t, 3, e.Version)
+	require.EqualValues(t, "", e.UndoState)
+	require.Equal(t, t0.Add(5*time.Minute), e.EnteredAt[types.PendingTxState])
+
+	events := e.UncommittedEventsSm()
+	require.Equal(t, TransitionEvent{
+		Sequence: 3, Kind: UndoneEventKind, From: types.ActiveTxState, To: types.PendingTxState,
+		Version: 3, OccurredAt: t0.Add(5 * time.Minute),
+	}, events[2])
+
+	// an undo is not undone, nor is any change other than an approval
+	err := e.UndoSm()
+	require.ErrorIs(t, err, ErrNothingToUndo)
+	require.Equal(t, NothingToUndoError, ErrorKindOf(err))
+	require.Nil(t, e.ModifyPendingSm())
+	require.ErrorIs(t, e.UndoSm(), ErrNothingToUndo)
+
+	s, err := Replay(e.WorkflowSm(), nil, events)
+	require.Nil(t, err)
+	require.EqualValues(t, types.PendingTxState, s.State)
+	require.EqualValues(t, 3, s.Version)
+
+	_, err = Replay(e.WorkflowSm(), nil, []TransitionEvent{events[0], {Sequence: 2, Kind: UndoneEventKind, From: types.PendingTxState, To: types.ActiveTxState, Version: 2}})
+	require.NotNil(t, err)
+}
+
+func Test_TxStateMachineClock_UndoSm_terminal(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	c := NewManualClock(t0)
+	e := newUndoEntity(t, c, types.RemovePendingTxState)
+
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.EqualValues(t, types.RemovedTxState, e.State)
+
+	c.Advance(11 * time.Minute)
+	err := e.UndoSm()
+	require.ErrorIs(t, err, ErrUndoExpired)
+	require.Equal(t, UndoExpiredError, ErrorKindOf(err))
+	require.EqualValues(t, types.RemovedTxState, e.State)
+	require.EqualValues(t, 2, e.Version)
+
+	// within the window, a terminal state is left like any other
+	e = newUndoEntity(t, c, types.RemovePendingTxState)
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	c.Advance(10 * time.Minute)
+	require.Nil(t, e.UndoSm())
+	require.EqualValues(t, types.RemovePendingTxState, e.State)
+
+	// the undo state is kept by snapshots and replays
+	e = newUndoEntity(t, c, types.PendingTxState)
+	require.Nil(t, e.ApproveSm())
+	r := newTrackedOrder(t, e.WorkflowSm())
+	snapshot := e.SnapshotSm()
+	require.Nil(t, r.ReplaySm(&snapshot, nil))
+	require.EqualValues(t, types.PendingTxState, r.UndoState)
+	require.Nil(t, r.UndoSm())
+}
+
+func Test_TxStateMachineClock_UndoSm_disabled(t *testing.T) {
+	e := &TxStateMachineClock{State: types.PendingTxState}
+	require.Nil(t, e.ApproveSm())
+	require.ErrorIs(t, e.UndoSm(), ErrForbidden)
+	require.EqualValues(t, types.ActiveTxState, e.State)
+}
+
+func Test_TxStateMachineClock_UndoSm_restores_lifecycle(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	c := NewManualClock(t0)
+	e := newUndoEntity(t, c, types.RemovePendingTxState)
+
+	// an approval entering a state for the first time leaves no time behind
+	c.Advance(time.Minute)
+	require.Nil(t, e.ApproveSm())
+	require.Equal(t, t0.Add(time.Minute), *e.RemovedAt)
+	c.Advance(time.Minute)
+	require.Nil(t, e.UndoSm())
+	require.Nil(t, e.RemovedAt)
+	require.NotContains(t, e.EnteredAt, types.RemovedTxState)
+	require.Nil(t, e.ApprovedAt)
+
+	// an approval entering a state again leaves the time it was entered before
+	e = newUndoEntity(t, c, types.PendingTxState)
+	activated := c.Advance(time.Minute)
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.Nil(t, e.ModifyPendingSm())
+	e.ResetTicked()
+	c.Advance(time.Minute)
+	require.Nil(t, e.ApproveSm())
+	e.ResetTicked()
+	require.Equal(t, activated, *e.Undo.ReplacedAt)
+	c.Advance(time.Minute)
+	require.Nil(t, e.UndoSm())
+	require.Equal(t, activated, *e.ActivatedAt)
+	require.Equal(t, activated, e.EnteredAt[types.ActiveTxState])
+	require.EqualValues(t, types.ModifyPendingTxState, e.State)
+
+	s, err := Replay(e.WorkflowSm(), nil, e.UncommittedEventsSm())
+	require.Nil(t, err)
+	require.Equal(t, e.SnapshotSm(), s)
+}
@@ -0,0 +1,11 @@
+package types
+
+// TxCategory groups states that are treated alike, e.g. every state waiting for an approval.
+type TxCategory string
+
+const (
+	PendingTxCategory  TxCategory = "pending"
+	ActiveTxCategory   TxCategory = "active"
+	InactiveTxCategory TxCategory = "inactive"
+	ClosedTxCategory   TxCategory = "closed"
+)
@@ -0,0 +1,32 @@
+package types
+
+type TxEvent string
+
+const (
+	// Request events move an entity to the state of the same name, see RequestTxEvent.
+	PendingTxEvent         TxEvent = "pending"
+	ModifyPendingTxEvent   TxEvent = "modify_pending"
+	RemovePendingTxEvent   TxEvent = "remove_pending"
+	InactivePendingTxEvent TxEvent = "inactive_pending"
+	ActivePendingTxEvent   TxEvent = "active_pending"
+
+	ApproveTxEvent TxEvent = "approve"
+	CancelTxEvent  TxEvent = "cancel"
+)
+
+// RequestTxEvent returns the request event that moves an entity to s, if s is requested by one.
+func RequestTxEvent(s TxState) (TxEvent, bool) {
+	switch s {
+	case PendingTxState:
+		return PendingTxEvent, true
+	case ModifyPendingTxState:
+		return ModifyPendingTxEvent, true
+	case RemovePendingTxState:
+		return RemovePendingTxEvent, true
+	case InactivePendingTxState:
+		return InactivePendingTxEvent, true
+	case ActivePendingTxState:
+		return ActivePendingTxEvent, true
+	}
+	return "", false
+}
@@ -4,11 +4,8 @@ type TxState string
 
 const (
 	// MUST NOT be more than 32 characters
-	// pending > active(canceled)
-	// active > modify_pending > active
-	// active > remove_pending > removed
-	// active > inactive_pending > inactive
-	// inactive > active_pending > active
+	// Use TxStateCode to store a state as a smallint instead.
+	// See state.DefaultWorkflow for the transitions between them.
 
 	PendingTxState         TxState = "pending"
 	ModifyPendingTxState   TxState = "modify_pending"
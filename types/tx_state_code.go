@@ -0,0 +1,164 @@
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// TxStateCode is a compact numeric representation of TxState.
+// It is stored as a smallint, which is cheaper than the string column for high-volume tables.
+//
+// Codes MUST stay stable. Once a code is assigned to a state it is never reused for another state,
+// and new states get new codes.
+type TxStateCode int16
+
+const (
+	UnknownTxStateCode         TxStateCode = 0
+	PendingTxStateCode         TxStateCode = 1
+	ModifyPendingTxStateCode   TxStateCode = 2
+	ActiveTxStateCode          TxStateCode = 3
+	CanceledTxStateCode        TxStateCode = 4
+	RemovePendingTxStateCode   TxStateCode = 5
+	RemovedTxStateCode         TxStateCode = 6
+	InactivePendingTxStateCode TxStateCode = 7
+	InactiveTxStateCode        TxStateCode = 8
+	ActivePendingTxStateCode   TxStateCode = 9
+)
+
+var (
+	txStateCodeMu sync.RWMutex
+	txStateByCode = map[TxStateCode]TxState{}
+	txCodeByState = map[TxState]TxStateCode{}
+)
+
+func init() {
+	mustRegisterTxStateCode(PendingTxState, PendingTxStateCode)
+	mustRegisterTxStateCode(ModifyPendingTxState, ModifyPendingTxStateCode)
+	mustRegisterTxStateCode(ActiveTxState, ActiveTxStateCode)
+	mustRegisterTxStateCode(CanceledTxState, CanceledTxStateCode)
+	mustRegisterTxStateCode(RemovePendingTxState, RemovePendingTxStateCode)
+	mustRegisterTxStateCode(RemovedTxState, RemovedTxStateCode)
+	mustRegisterTxStateCode(InactivePendingTxState, InactivePendingTxStateCode)
+	mustRegisterTxStateCode(InactiveTxState, InactiveTxStateCode)
+	mustRegisterTxStateCode(ActivePendingTxState, ActivePendingTxStateCode)
+}
+
+func mustRegisterTxStateCode(s TxState, c TxStateCode) {
+	if err := RegisterTxStateCode(s, c); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterTxStateCode binds c to s in the process-wide registry.
+// Registering the same pair twice is allowed, but neither s nor c may be rebound to something else.
+func RegisterTxStateCode(s TxState, c TxStateCode) error {
+	if s == "" {
+		return fmt.Errorf("state must not be empty")
+	}
+	if c <= UnknownTxStateCode {
+		return fmt.Errorf("code of %q must be positive: %d", s, c)
+	}
+
+	txStateCodeMu.Lock()
+	defer txStateCodeMu.Unlock()
+
+	if v, ok := txStateByCode[c]; ok && v != s {
+		return fmt.Errorf("code %d is already assigned to %q", c, v)
+	}
+	if v, ok := txCodeByState[s]; ok && v != c {
+		return fmt.Errorf("state %q already has code %d", s, v)
+	}
+	txStateByCode[c] = s
+	txCodeByState[s] = c
+	return nil
+}
+
+// TxStateCodes returns every registered code ordered by code.
+func TxStateCodes() []TxStateCode {
+	txStateCodeMu.RLock()
+	defer txStateCodeMu.RUnlock()
+
+	codes := make([]TxStateCode, 0, len(txStateByCode))
+	for c := range txStateByCode {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Code returns the registered code of s, or UnknownTxStateCode if s has none.
+func (s TxState) Code() TxStateCode {
+	txStateCodeMu.RLock()
+	defer txStateCodeMu.RUnlock()
+	return txCodeByState[s]
+}
+
+// State returns the registered state of c, or an empty TxState if c has none.
+func (c TxStateCode) State() TxState {
+	txStateCodeMu.RLock()
+	defer txStateCodeMu.RUnlock()
+	return txStateByCode[c]
+}
+
+func (c TxStateCode) String() string {
+	if s := c.State(); s != "" {
+		return string(s)
+	}
+	return strconv.Itoa(int(c))
+}
+
+// Value implements driver.Valuer. Unknown codes are stored as they are.
+func (c TxStateCode) Value() (driver.Value, error) {
+	return int64(c), nil
+}
+
+// Scan implements sql.Scanner.
+// Besides integers it accepts state names, so a column can be read while it is being migrated.
+func (c *TxStateCode) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*c = UnknownTxStateCode
+		return nil
+	case int64:
+		return c.setInt(v)
+	case int32:
+		return c.setInt(int64(v))
+	case int16:
+		return c.setInt(int64(v))
+	case int:
+		return c.setInt(int64(v))
+	case []byte:
+		return c.setText(string(v))
+	case string:
+		return c.setText(v)
+	}
+	return fmt.Errorf("cannot scan %T into TxStateCode", src)
+}
+
+func (c *TxStateCode) setInt(v int64) error {
+	if v < -1<<15 || v > 1<<15-1 {
+		return fmt.Errorf("state code out of range: %d", v)
+	}
+	*c = TxStateCode(v)
+	return nil
+}
+
+func (c *TxStateCode) setText(v string) error {
+	if v == "" {
+		*c = UnknownTxStateCode
+		return nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 16); err == nil {
+		*c = TxStateCode(n)
+		return nil
+	}
+	code := TxState(v).Code()
+	if code == UnknownTxStateCode {
+		return fmt.Errorf("state %q has no code", v)
+	}
+	*c = code
+	return nil
+}
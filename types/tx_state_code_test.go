@@ -0,0 +1,79 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TxStateCode_stable(t *testing.T) {
+	// codes are persisted, they must never change
+	expected := map[TxState]TxStateCode{
+		PendingTxState:         1,
+		ModifyPendingTxState:   2,
+		ActiveTxState:          3,
+		CanceledTxState:        4,
+		RemovePendingTxState:   5,
+		RemovedTxState:         6,
+		InactivePendingTxState: 7,
+		InactiveTxState:        8,
+		ActivePendingTxState:   9,
+	}
+	for s, c := range expected {
+		require.EqualValues(t, c, s.Code())
+		require.EqualValues(t, s, c.State())
+	}
+	require.EqualValues(t, UnknownTxStateCode, TxState("unknown").Code())
+	require.EqualValues(t, "", TxStateCode(100).State())
+}
+
+func Test_RegisterTxStateCode(t *testing.T) {
+	err := RegisterTxStateCode(ActiveTxState, ActiveTxStateCode)
+	require.Nil(t, err)
+
+	err = RegisterTxStateCode(TxState("archived"), ActiveTxStateCode)
+	require.NotNil(t, err)
+
+	err = RegisterTxStateCode(ActiveTxState, TxStateCode(1000))
+	require.NotNil(t, err)
+
+	err = RegisterTxStateCode(TxState("archived"), UnknownTxStateCode)
+	require.NotNil(t, err)
+
+	err = RegisterTxStateCode(TxState("archived"), TxStateCode(1000))
+	require.Nil(t, err)
+	require.EqualValues(t, TxStateCode(1000), TxState("archived").Code())
+	require.EqualValues(t, "archived", TxStateCode(1000).String())
+}
+
+func Test_TxStateCode_ScanValue(t *testing.T) {
+	v, err := RemovedTxStateCode.Value()
+	require.Nil(t, err)
+	require.EqualValues(t, int64(6), v)
+
+	var c TxStateCode
+	require.Nil(t, c.Scan(int64(3)))
+	require.EqualValues(t, ActiveTxStateCode, c)
+
+	require.Nil(t, c.Scan([]byte("7")))
+	require.EqualValues(t, InactivePendingTxStateCode, c)
+
+	require.Nil(t, c.Scan("remove_pending"))
+	require.EqualValues(t, RemovePendingTxStateCode, c)
+
+	require.Nil(t, c.Scan(nil))
+	require.EqualValues(t, UnknownTxStateCode, c)
+
+	require.NotNil(t, c.Scan("unknown"))
+	require.NotNil(t, c.Scan(int64(1<<20)))
+	require.NotNil(t, c.Scan(1.5))
+}
+
+func Test_TxStateCode_JSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		State TxStateCode `json:"state"`
+	}{State: CanceledTxStateCode})
+	require.Nil(t, err)
+	require.EqualValues(t, `{"state":4}`, string(b))
+}
@@ -0,0 +1,22 @@
+package types
+
+// TxTransition moves an entity from From to To when Event fires.
+// An empty From means the entity has no state yet.
+// If From equals To the event is accepted but nothing changes.
+type TxTransition struct {
+	From  TxState `json:"from"`
+	Event TxEvent `json:"event"`
+	To    TxState `json:"to"`
+
+	// Guard names a condition registered on the workflow that must hold for the transition to happen.
+	Guard string `json:"guard,omitempty"`
+}
+
+// TxStateSpec describes a state of a workflow.
+type TxStateSpec struct {
+	Name     TxState    `json:"name"`
+	Category TxCategory `json:"category,omitempty"`
+
+	// Terminal states never leave once entered.
+	Terminal bool `json:"terminal,omitempty"`
+}
@@ -0,0 +1,98 @@
+package state
+
+// UnitMember is an entity whose Version is bumped at most once per unit of work,
+// e.g. a host of TxClock or TxStateMachineClock.
+type UnitMember interface {
+	BeginUnit()
+	CommitUnit()
+	RollbackUnit()
+}
+
+// UnitOfWork bumps the Version of each of its members at most once, however many times they Tick.
+// Units nest: only the outermost Commit commits, while Rollback ends the whole unit.
+// Transitions of members made during the unit are published when it commits, and never if it is rolled back.
+// It is not safe for concurrent use.
+//
+//	u := state.Begin(order, payment)
+//	defer u.Rollback()
+//	...
+//	if err := db.Save(order).Error; err != nil {
+//		return err
+//	}
+//	u.Commit()
+type UnitOfWork struct {
+	members []UnitMember
+	depth   int
+}
+
+// Begin starts a unit of work with members.
+func Begin(members ...UnitMember) *UnitOfWork {
+	u := &UnitOfWork{depth: 1}
+	u.Add(members...)
+	return u
+}
+
+// Add makes members join u. A member already in u is ignored.
+func (u *UnitOfWork) Add(members ...UnitMember) {
+	if u.depth == 0 {
+		return
+	}
+	for _, m := range members {
+		if u.has(m) {
+			continue
+		}
+		m.BeginUnit()
+		u.members = append(u.members, m)
+	}
+}
+
+func (u *UnitOfWork) has(m UnitMember) bool {
+	for _, v := range u.members {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Begin nests a unit in u. It must be matched with Commit or Rollback.
+func (u *UnitOfWork) Begin() *UnitOfWork {
+	if u.depth > 0 {
+		u.depth++
+	}
+	return u
+}
+
+// Commit ends the innermost unit. Ending the outermost one lets the next Tick of every member bump its Version again.
+// It does nothing once u has ended.
+func (u *UnitOfWork) Commit() {
+	if u.depth == 0 {
+		return
+	}
+	u.depth--
+	if u.depth > 0 {
+		return
+	}
+	for _, m := range u.members {
+		m.CommitUnit()
+	}
+	u.members = nil
+}
+
+// Rollback ends u, including the units it is nested in, and restores every member as it was when it joined.
+// It does nothing once u has ended, so it may be deferred after Commit.
+func (u *UnitOfWork) Rollback() {
+	if u.depth == 0 {
+		return
+	}
+	u.depth = 0
+	for _, m := range u.members {
+		m.RollbackUnit()
+	}
+	u.members = nil
+}
+
+// Done reports whether u has ended.
+func (u *UnitOfWork) Done() bool {
+	return u.depth == 0
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_UnitOfWork(t *testing.T) {
+	order := &TxStateMachineClock{}
+	payment := &TxClock{}
+
+	u := Begin(order, payment)
+	require.Nil(t, order.PendingSm())
+	require.Nil(t, order.ApproveSm())
+	payment.Tick()
+	payment.Tick()
+
+	// a nested unit does not bump again
+	inner := u.Begin()
+	require.Nil(t, order.ModifyPendingSm())
+	inner.Commit()
+	require.False(t, u.Done())
+	u.Add(order)
+
+	u.Commit()
+	require.True(t, u.Done())
+	require.Equal(t, uint64(1), order.Version)
+	require.Equal(t, types.ModifyPendingTxState, order.State)
+	require.Equal(t, uint64(1), payment.Version)
+	require.False(t, order.VersionTicked)
+	require.False(t, payment.VersionTicked)
+
+	// the next unit bumps again
+	u = Begin(order, payment)
+	require.Nil(t, order.ApproveSm())
+	payment.Tick()
+	u.Commit()
+	u.Rollback()
+	require.Equal(t, uint64(2), order.Version)
+	require.Equal(t, types.ActiveTxState, order.State)
+	require.Equal(t, uint64(2), payment.Version)
+}
+
+func Test_UnitOfWork_Rollback(t *testing.T) {
+	c := NewManualClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	w, err := DefaultWorkflow().With(WithClock(c), WithEventSourcing())
+	require.Nil(t, err)
+	order := newTrackedOrder(t, w)
+	require.Nil(t, order.PendingSm())
+	order.ResetTicked()
+	payment := &TxClock{}
+
+	u := Begin(order, payment)
+	c.Advance(time.Hour)
+	require.Nil(t, order.ApproveSm())
+	payment.Tick()
+	inner := u.Begin()
+	inner.Rollback()
+	require.True(t, u.Done())
+	u.Commit()
+
+	require.Equal(t, types.PendingTxState, order.State)
+	require.Equal(t, uint64(1), order.Version)
+	require.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *order.UpdatedAt)
+	require.Nil(t, order.ActivatedAt)
+	require.NotContains(t, order.EnteredAt, types.ActiveTxState)
+	require.Len(t, order.UncommittedEventsSm(), 1)
+	require.Equal(t, uint64(1), order.Sequence)
+	require.Equal(t, uint64(0), payment.Version)
+	require.Nil(t, payment.CreatedAt)
+
+	// the entity works as before the unit
+	require.True(t, order.IsPendingSm())
+	require.Nil(t, order.ApproveSm())
+	require.Equal(t, uint64(2), order.Version)
+	require.Equal(t, uint64(2), order.UncommittedEventsSm()[1].Sequence)
+}
+
+func Test_UnitOfWork_publishes_on_commit(t *testing.T) {
+	b := NewBus()
+	var got []types.TxState
+	_, err := b.Subscribe(Filter{}, func(t Transition) { got = append(got, t.To) })
+	require.Nil(t, err)
+	o := newBusOrder(t, b, "o-1")
+
+	u := Begin(o)
+	require.Nil(t, o.PendingSm())
+	inner := u.Begin()
+	require.Nil(t, o.ApproveSm())
+	inner.Commit()
+	require.Empty(t, got)
+	u.Commit()
+	require.Equal(t, []types.TxState{types.PendingTxState, types.ActiveTxState}, got)
+
+	// a rolled back unit publishes nothing
+	u = Begin(o)
+	require.Nil(t, o.RemovePendingSm())
+	u.Rollback()
+	u.Commit()
+	require.Len(t, got, 2)
+	require.Nil(t, o.InactivePendingSm())
+	require.Equal(t, types.InactivePendingTxState, got[2])
+}
+
+func Test_TxClock_nested_units(t *testing.T) {
+	e := &TxClock{}
+	e.BeginUnit()
+	e.Tick()
+	e.BeginUnit()
+	e.Tick()
+	e.CommitUnit()
+	e.Tick()
+	e.CommitUnit()
+	require.Equal(t, uint64(1), e.Version)
+
+	e.BeginUnit()
+	e.Tick()
+	e.CommitUnit()
+	e.CommitUnit()
+	require.Equal(t, uint64(2), e.Version)
+}
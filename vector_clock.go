@@ -0,0 +1,188 @@
+package state
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/wonksing/state/types"
+)
+
+// CausalOrder is how two vector clocks are ordered.
+type CausalOrder string
+
+const (
+	CausallyBefore CausalOrder = "before"
+	CausallyAfter  CausalOrder = "after"
+	CausallyEqual  CausalOrder = "equal"
+	// Concurrent means neither clock has seen every update of the other.
+	Concurrent CausalOrder = "concurrent"
+)
+
+// Vector counts the updates made by each node. A missing node counts zero.
+type Vector map[string]uint64
+
+func (v Vector) Clone() Vector {
+	if v == nil {
+		return nil
+	}
+	res := make(Vector, len(v))
+	for k, n := range v {
+		res[k] = n
+	}
+	return res
+}
+
+// Compare returns how v is ordered relative to o.
+func (v Vector) Compare(o Vector) CausalOrder {
+	less, greater := false, false
+	for k, n := range v {
+		if m := o[k]; n < m {
+			less = true
+		} else if n > m {
+			greater = true
+		}
+	}
+	for k, m := range o {
+		if _, ok := v[k]; !ok && m > 0 {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return CausallyBefore
+	case greater:
+		return CausallyAfter
+	}
+	return CausallyEqual
+}
+
+// Merge returns the counters of v and o, taking the larger of each.
+func (v Vector) Merge(o Vector) Vector {
+	res := v.Clone()
+	if res == nil {
+		res = make(Vector, len(o))
+	}
+	for k, m := range o {
+		if m > res[k] {
+			res[k] = m
+		}
+	}
+	return res
+}
+
+// Value implements driver.Valuer. v is stored as a JSON object.
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]uint64(v))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (v *Vector) Scan(value any) error {
+	var b []byte
+	switch s := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		b = s
+	case string:
+		b = []byte(s)
+	default:
+		return fmt.Errorf("unable to scan %T into Vector", value)
+	}
+	var m map[string]uint64
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*v = m
+	return nil
+}
+
+// VectorClock tells whether updates of an entity edited on several nodes are causally ordered or concurrent.
+// It is a Ticker counting the updates made by Node, e.g. in Stateful[VectorClock, *VectorClock].
+// Node is the node running the process and is not stored; set it before the first transition.
+type VectorClock struct {
+	Vector Vector `gorm:"column:vector_clock;type:text" json:"vector_clock,omitempty"`
+	Node   string `gorm:"-:all" json:"-"`
+	Ticked bool   `gorm:"-:all" json:"-"`
+}
+
+// Tick counts an update made by Node. It returns immediately if the update is already counted.
+// The counters are copied, so a copy of e taken earlier, e.g. by a unit of work, keeps them.
+func (e *VectorClock) Tick() {
+	if e.Ticked {
+		return
+	}
+	e.Ticked = true
+	e.TickNode(e.Node)
+}
+
+// ResetTicked lets the next Tick count another update.
+func (e *VectorClock) ResetTicked() {
+	e.Ticked = false
+}
+
+// TickNode counts an update made by node.
+func (e *VectorClock) TickNode(node string) {
+	e.Vector = e.Vector.Merge(Vector{node: e.Vector[node] + 1})
+}
+
+// Merge makes e account for every update o has seen.
+func (e *VectorClock) Merge(o *VectorClock) {
+	e.Vector = e.Vector.Merge(o.Vector)
+}
+
+// Compare returns how e is ordered relative to o.
+func (e *VectorClock) Compare(o *VectorClock) CausalOrder {
+	return e.Vector.Compare(o.Vector)
+}
+
+// Replica returns the replica of an entity in state s, as seen by Node.
+func (e *VectorClock) Replica(s types.TxState) Replica {
+	return Replica{Node: e.Node, State: s, Vector: e.Vector.Clone()}
+}
+
+// Replica is the state of an entity as seen by one node.
+type Replica struct {
+	Node   string
+	State  types.TxState
+	Vector Vector
+}
+
+// StateConflict is returned by ReconcileState when two replicas moved to different states concurrently,
+// e.g. one approved while the other canceled.
+type StateConflict struct {
+	Local  Replica
+	Remote Replica
+}
+
+func (e *StateConflict) Error() string {
+	return fmt.Sprintf("concurrent transitions: %s on %s but %s on %s",
+		e.Local.State, e.Local.Node, e.Remote.State, e.Remote.Node)
+}
+
+// ReconcileState returns the replica local should become after receiving remote.
+// The later replica wins, with the clocks merged. Concurrent replicas in the same state are merged,
+// and concurrent replicas in different states fail with a *StateConflict for the caller to resolve.
+func ReconcileState(local, remote Replica) (Replica, error) {
+	res := Replica{Node: local.Node, State: local.State, Vector: local.Vector.Merge(remote.Vector)}
+	switch local.Vector.Compare(remote.Vector) {
+	case CausallyBefore:
+		res.State = remote.State
+	case Concurrent:
+		if local.State != remote.State {
+			return local, &StateConflict{Local: local, Remote: remote}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_VectorClock(t *testing.T) {
+	a := &VectorClock{}
+	b := &VectorClock{}
+	require.Equal(t, CausallyEqual, a.Compare(b))
+
+	a.TickNode("phone")
+	require.Equal(t, CausallyAfter, a.Compare(b))
+	require.Equal(t, CausallyBefore, b.Compare(a))
+
+	b.Merge(a)
+	require.Equal(t, CausallyEqual, a.Compare(b))
+
+	a.TickNode("phone")
+	b.TickNode("laptop")
+	require.Equal(t, Concurrent, a.Compare(b))
+	require.Equal(t, Concurrent, b.Compare(a))
+
+	a.Merge(b)
+	require.Equal(t, Vector{"phone": 2, "laptop": 1}, a.Vector)
+	require.Equal(t, CausallyAfter, a.Compare(b))
+
+	// a zero counter is the same as a missing one
+	require.Equal(t, CausallyEqual, Vector{"x": 0}.Compare(nil))
+}
+
+func Test_Vector_sql(t *testing.T) {
+	v := Vector{"a": 1, "b": 2}
+	dv, err := v.Value()
+	require.Nil(t, err)
+	require.Equal(t, `{"a":1,"b":2}`, dv)
+
+	var got Vector
+	require.Nil(t, got.Scan([]byte(dv.(string))))
+	require.Equal(t, v, got)
+	require.Nil(t, got.Scan(nil))
+	require.Nil(t, got)
+	require.NotNil(t, got.Scan(1))
+}
+
+func Test_ReconcileState(t *testing.T) {
+	base := Vector{"a": 1, "b": 1}
+
+	// b approved after seeing every update of a
+	local := Replica{Node: "a", State: types.PendingTxState, Vector: base}
+	remote := Replica{Node: "b", State: types.ActiveTxState, Vector: base.Merge(Vector{"b": 2})}
+	res, err := ReconcileState(local, remote)
+	require.Nil(t, err)
+	require.Equal(t, Replica{Node: "a", State: types.ActiveTxState, Vector: Vector{"a": 1, "b": 2}}, res)
+
+	// a is ahead of b
+	res, err = ReconcileState(remote, local)
+	require.Nil(t, err)
+	require.Equal(t, types.ActiveTxState, res.State)
+
+	// a approved while b canceled
+	local = Replica{Node: "a", State: types.ActiveTxState, Vector: base.Merge(Vector{"a": 2})}
+	remote = Replica{Node: "b", State: types.CanceledTxState, Vector: base.Merge(Vector{"b": 2})}
+	res, err = ReconcileState(local, remote)
+	var conflict *StateConflict
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, types.CanceledTxState, conflict.Remote.State)
+	require.Equal(t, local, res)
+	require.Equal(t, "concurrent transitions: active on a but canceled on b", err.Error())
+
+	// both approved
+	remote.State = types.ActiveTxState
+	res, err = ReconcileState(local, remote)
+	require.Nil(t, err)
+	require.Equal(t, Vector{"a": 2, "b": 2}, res.Vector)
+}
+
+func Test_VectorClock_Stateful(t *testing.T) {
+	a := &Stateful[VectorClock, *VectorClock]{Clock: VectorClock{Node: "a"}}
+	require.Nil(t, a.PendingSm())
+	require.Nil(t, a.ModifyPendingSm())
+	require.Equal(t, Vector{"a": 1}, a.Clock.Vector)
+
+	// b receives the entity from a
+	b := &Stateful[VectorClock, *VectorClock]{State: a.State, Clock: VectorClock{Node: "b", Vector: a.Clock.Vector.Clone()}}
+	a.ResetTicked()
+	require.Nil(t, a.ApproveSm())
+	require.Equal(t, Vector{"a": 2}, a.Clock.Vector)
+
+	res, err := ReconcileState(b.Clock.Replica(b.State), a.Clock.Replica(a.State))
+	require.Nil(t, err)
+	require.Equal(t, Replica{Node: "b", State: types.ActiveTxState, Vector: Vector{"a": 2}}, res)
+	b.State, b.Clock.Vector = res.State, res.Vector
+
+	// a rolled back unit of work forgets its update
+	b.ResetTicked()
+	b.BeginUnit()
+	require.Nil(t, b.InactivePendingSm())
+	require.Equal(t, Vector{"a": 2, "b": 1}, b.Clock.Vector)
+	b.RollbackUnit()
+	require.Equal(t, Vector{"a": 2}, b.Clock.Vector)
+
+	// a and b change the entity concurrently
+	a.ResetTicked()
+	require.Nil(t, a.RemovePendingSm())
+	require.Nil(t, b.InactivePendingSm())
+	_, err = ReconcileState(a.Clock.Replica(a.State), b.Clock.Replica(b.State))
+	var conflict *StateConflict
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, "concurrent transitions: remove_pending on a but inactive_pending on b", err.Error())
+}
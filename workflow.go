@@ -0,0 +1,283 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/wonksing/state/internal"
+	"github.com/wonksing/state/types"
+)
+
+var defaultWorkflow atomic.Pointer[Workflow]
+
+func init() {
+	defaultWorkflow.Store(&Workflow{table: internal.DefaultTxWorkflow})
+}
+
+// DefaultWorkflow returns the lifecycle followed by TxStateMachine and TxStateMachineClock
+// unless they are given another one with SetWorkflowSm.
+func DefaultWorkflow() *Workflow {
+	return defaultWorkflow.Load()
+}
+
+// SetDefaultWorkflow replaces the workflow returned by DefaultWorkflow for the whole process.
+// It is meant to be called once at startup, e.g. with DefaultWorkflow().With(WithEventSourcing()).
+func SetDefaultWorkflow(w *Workflow) error {
+	if w == nil {
+		return errors.New("workflow is nil")
+	}
+	defaultWorkflow.Store(w)
+	return nil
+}
+
+// Workflow is a set of states and the transitions between them.
+type Workflow struct {
+	table *internal.TxWorkflow
+	opts  workflowOptions
+}
+
+type workflowOptions struct {
+	events            []types.TxEvent
+	guards            map[string]internal.TxGuard
+	eventSourcing     bool
+	bus               *Bus
+	observers         []TransitionObserver
+	clock             Clock
+	hlc               *HLCSource
+	hlcGiven          bool
+	sla               map[types.TxState]SLARule
+	calendar          Calendar
+	initial           types.TxState
+	force             *ForcePolicy
+	noForce           bool
+	unrestrictedForce bool
+	authorizers       []Authorizer
+	permissions       []Permission
+	roles             RoleResolver
+	escalation        []EscalationRule
+	undoWindow        time.Duration
+}
+
+func (o workflowOptions) clone() workflowOptions {
+	res := o
+	res.events = append([]types.TxEvent(nil), o.events...)
+	res.observers = append([]TransitionObserver(nil), o.observers...)
+	res.authorizers = append([]Authorizer(nil), o.authorizers...)
+	res.permissions = append([]Permission(nil), o.permissions...)
+	res.escalation = append([]EscalationRule(nil), o.escalation...)
+	res.sla = make(map[types.TxState]SLARule, len(o.sla))
+	for k, v := range o.sla {
+		res.sla[k] = v
+	}
+	res.guards = make(map[string]internal.TxGuard, len(o.guards))
+	for k, v := range o.guards {
+		res.guards[k] = v
+	}
+	return res
+}
+
+// WorkflowOption configures a Workflow.
+type WorkflowOption func(o *workflowOptions)
+
+// WithGuard registers g under name. Transitions whose Guard is name happen only if g returns nil.
+func WithGuard(name string, g func(t types.TxTransition) error) WorkflowOption {
+	return func(o *workflowOptions) {
+		if o.guards == nil {
+			o.guards = make(map[string]internal.TxGuard)
+		}
+		o.guards[name] = g
+	}
+}
+
+// WithInitialState makes s the state InitSm gives an entity by default, instead of the initial state
+// the workflow is built with, e.g. DefaultWorkflow().With(WithInitialState(types.ActiveTxState)) for bulk imports.
+func WithInitialState(s types.TxState) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.initial = s
+	}
+}
+
+// WithEvents declares the events of a workflow. Transitions may only use declared events.
+// Without it, the events are collected from the transitions.
+func WithEvents(events ...types.TxEvent) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.events = append(o.events, events...)
+	}
+}
+
+// WithEventSourcing makes every *Sm call of a Stateful, like TxStateMachineClock, append a TransitionEvent
+// to the uncommitted events of the entity. See Replay.
+func WithEventSourcing() WorkflowOption {
+	return func(o *workflowOptions) {
+		o.eventSourcing = true
+	}
+}
+
+// EventSourcing reports whether w was built WithEventSourcing.
+func (w *Workflow) EventSourcing() bool {
+	return w.opts.eventSourcing
+}
+
+// WithBus publishes the transitions of entities following the workflow to b instead of DefaultBus.
+func WithBus(b *Bus) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.bus = b
+	}
+}
+
+// WithClock makes Tick of entities following the workflow tell the time with c instead of DefaultClock.
+func WithClock(c Clock) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.clock = c
+		if !o.hlcGiven {
+			o.hlc = nil
+		}
+	}
+}
+
+// WithHLCSource makes TxHybridClock of entities following the workflow tick with s.
+// Without it, a workflow using WithClock has its own source reading that clock, and others use DefaultHLCSource.
+func WithHLCSource(s *HLCSource) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.hlc, o.hlcGiven = s, s != nil
+	}
+}
+
+// WithCalendar makes SLA rules of the workflow count the working time of c only.
+func WithCalendar(c Calendar) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.calendar = c
+	}
+}
+
+// WithObserver makes o observe every transition attempted on entities following the workflow.
+// Observers are called in the order they are added.
+func WithObserver(o TransitionObserver) WorkflowOption {
+	return func(opts *workflowOptions) {
+		opts.observers = append(opts.observers, o)
+	}
+}
+
+// NewWorkflow returns a Workflow starting at initial.
+func NewWorkflow(name string, initial types.TxState, states []types.TxStateSpec, transitions []types.TxTransition, opts ...WorkflowOption) (*Workflow, error) {
+	var o workflowOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return newWorkflow(name, initial, states, transitions, o)
+}
+
+// With returns a copy of w with opts applied on top of the options w was built with.
+func (w *Workflow) With(opts ...WorkflowOption) (*Workflow, error) {
+	o := w.opts.clone()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return newWorkflow(w.Name(), w.Initial(), w.table.States, w.table.Transitions, o)
+}
+
+func newWorkflow(name string, initial types.TxState, states []types.TxStateSpec, transitions []types.TxTransition, o workflowOptions) (*Workflow, error) {
+	if o.initial != "" {
+		initial = o.initial
+	}
+	table, err := internal.NewTxWorkflow(name, initial,
+		append([]types.TxStateSpec(nil), states...),
+		o.events,
+		append([]types.TxTransition(nil), transitions...),
+		o.guards)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePermissions(table, o.permissions); err != nil {
+		return nil, err
+	}
+	if err := validateEscalation(table, o.escalation); err != nil {
+		return nil, err
+	}
+	if o.force != nil {
+		if err := o.force.validate(table); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range o.sla {
+		if !table.HasState(r.State) {
+			return nil, fmt.Errorf("state of SLA rule is not declared: %s", r.State)
+		}
+		if r.Breach <= 0 || r.Warning < 0 || r.Warning >= r.Breach {
+			return nil, fmt.Errorf("SLA rule of %s must have a positive breach after its warning", r.State)
+		}
+	}
+	if o.hlc == nil && o.clock != nil {
+		o.hlc = NewHLCSource(o.clock, 0)
+	}
+	return &Workflow{table: table, opts: o}, nil
+}
+
+func (w *Workflow) Name() string {
+	return w.table.Name
+}
+
+// Initial returns the state InitSm gives an entity by default.
+func (w *Workflow) Initial() types.TxState {
+	return w.table.Initial
+}
+
+// States returns the states of w in declaration order.
+func (w *Workflow) States() []types.TxStateSpec {
+	return append([]types.TxStateSpec(nil), w.table.States...)
+}
+
+// Events returns the events of w in declaration order.
+func (w *Workflow) Events() []types.TxEvent {
+	return append([]types.TxEvent(nil), w.table.Events...)
+}
+
+// Transitions returns the transitions of w in declaration order.
+func (w *Workflow) Transitions() []types.TxTransition {
+	return append([]types.TxTransition(nil), w.table.Transitions...)
+}
+
+// Outgoing returns the transitions leaving from in declaration order.
+// An empty from returns the transitions of an entity without a state.
+func (w *Workflow) Outgoing(from types.TxState) []types.TxTransition {
+	return w.table.Outgoing(from)
+}
+
+// Clock returns the clock of entities following w.
+func (w *Workflow) Clock() Clock {
+	if w.opts.clock == nil {
+		return DefaultClock()
+	}
+	return w.opts.clock
+}
+
+// HLCSource returns the source TxHybridClock of entities following w ticks with, see WithHLCSource.
+func (w *Workflow) HLCSource() *HLCSource {
+	if w.opts.hlc == nil {
+		return DefaultHLCSource()
+	}
+	return w.opts.hlc
+}
+
+// Calendar returns the calendar SLA rules of w count time with, AlwaysOpen by default.
+func (w *Workflow) Calendar() Calendar {
+	if w.opts.calendar == nil {
+		return AlwaysOpen
+	}
+	return w.opts.calendar
+}
+
+// Bus returns the bus the transitions of entities following w are published to.
+func (w *Workflow) Bus() *Bus {
+	if w.opts.bus == nil {
+		return DefaultBus()
+	}
+	return w.opts.bus
+}
+
+// HasState reports whether s is declared in w.
+func (w *Workflow) HasState(s types.TxState) bool {
+	return w.table.HasState(s)
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wonksing/state/types"
+)
+
+var diagramIDPattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ExportDOT renders w as a Graphviz digraph.
+// Terminal states have a double border and guarded transitions are dashed.
+func (w *Workflow) ExportDOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", w.Name())
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded];\n")
+	b.WriteString("\t\"__start__\" [label=\"\", shape=point];\n")
+	for _, s := range w.table.States {
+		if s.Terminal {
+			fmt.Fprintf(&b, "\t%q [peripheries=2];\n", s.Name)
+		} else {
+			fmt.Fprintf(&b, "\t%q;\n", s.Name)
+		}
+	}
+	if !w.hasStartTransition() {
+		fmt.Fprintf(&b, "\t\"__start__\" -> %q;\n", w.Initial())
+	}
+	for _, t := range w.table.Transitions {
+		from := string(t.From)
+		if from == "" {
+			from = "__start__"
+		}
+		if t.Guard != "" {
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q, style=dashed];\n", from, t.To, transitionLabel(t))
+		} else {
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", from, t.To, transitionLabel(t))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportMermaid renders w as a Mermaid state diagram.
+// Terminal states lead to the end marker and guards follow the event in brackets.
+func (w *Workflow) ExportMermaid() string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	w.writeStateDiagram(&b, "    ", ": ")
+	return b.String()
+}
+
+// ExportPlantUML renders w as a PlantUML state diagram.
+// Terminal states lead to the end marker and guards follow the event in brackets.
+func (w *Workflow) ExportPlantUML() string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	fmt.Fprintf(&b, "title %s\n", w.Name())
+	w.writeStateDiagram(&b, "", " : ")
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// writeStateDiagram writes the body shared by Mermaid and PlantUML.
+func (w *Workflow) writeStateDiagram(b *strings.Builder, indent, sep string) {
+	for _, s := range w.table.States {
+		if id := diagramID(s.Name); id != string(s.Name) {
+			fmt.Fprintf(b, "%sstate %q as %s\n", indent, s.Name, id)
+		}
+	}
+	if !w.hasStartTransition() {
+		fmt.Fprintf(b, "%s[*] --> %s\n", indent, diagramID(w.Initial()))
+	}
+	for _, t := range w.table.Transitions {
+		from := "[*]"
+		if t.From != "" {
+			from = diagramID(t.From)
+		}
+		fmt.Fprintf(b, "%s%s --> %s%s%s\n", indent, from, diagramID(t.To), sep, transitionLabel(t))
+	}
+	for _, s := range w.table.States {
+		if s.Terminal {
+			fmt.Fprintf(b, "%s%s --> [*]\n", indent, diagramID(s.Name))
+		}
+	}
+}
+
+func (w *Workflow) hasStartTransition() bool {
+	return len(w.table.Outgoing("")) > 0
+}
+
+func transitionLabel(t types.TxTransition) string {
+	if t.Guard != "" {
+		return fmt.Sprintf("%s [%s]", t.Event, t.Guard)
+	}
+	return string(t.Event)
+}
+
+func diagramID(s types.TxState) string {
+	return diagramIDPattern.ReplaceAllString(string(s), "_")
+}
@@ -0,0 +1,507 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/wonksing/state/types"
+	"gopkg.in/yaml.v3"
+)
+
+// A workflow file is YAML or JSON. JSON is read as YAML, so both report the same line numbers.
+//
+//	name: ticket              # required
+//	initial: open             # required, state InitSm gives an entity by default
+//	states:                   # required, at least one
+//	  - name: open            # required, unique
+//	    category: pending     # optional, e.g. pending, active, inactive or closed
+//	  - name: closed
+//	    category: closed
+//	    terminal: true        # optional, the state is never left once entered
+//	events:                   # required, every event used by transitions
+//	  - submit                # a name only
+//	  - name: close           # or a name with a description
+//	    description: close the ticket
+//	transitions:              # required
+//	  - from: open            # required, "" is an entity without a state
+//	    event: close          # required, one target per from and event
+//	    to: closed            # required
+//	    guard: approved       # optional, registered with WithGuard
+//	permissions:              # optional, see WithPermissions
+//	  - state: open           # optional, any state if omitted
+//	    event: close          # required
+//	    roles: [manager]      # required, at least one, given to actors by WithRoles
+//
+// Unknown keys are rejected.
+
+// WorkflowDefinition is a parsed workflow file.
+type WorkflowDefinition struct {
+	Name        string                 `yaml:"name" json:"name"`
+	Initial     types.TxState          `yaml:"initial" json:"initial"`
+	States      []StateDefinition      `yaml:"states" json:"states"`
+	Events      []EventDefinition      `yaml:"events" json:"events"`
+	Transitions []TransitionDefinition `yaml:"transitions" json:"transitions"`
+	Permissions []PermissionDefinition `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+
+	File string `yaml:"-" json:"-"`
+	Line int    `yaml:"-" json:"-"`
+}
+
+type StateDefinition struct {
+	Name     types.TxState    `yaml:"name" json:"name"`
+	Category types.TxCategory `yaml:"category,omitempty" json:"category,omitempty"`
+	Terminal bool             `yaml:"terminal,omitempty" json:"terminal,omitempty"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+type EventDefinition struct {
+	Name        types.TxEvent `yaml:"name" json:"name"`
+	Description string        `yaml:"description,omitempty" json:"description,omitempty"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+type TransitionDefinition struct {
+	From  types.TxState `yaml:"from" json:"from"`
+	Event types.TxEvent `yaml:"event" json:"event"`
+	To    types.TxState `yaml:"to" json:"to"`
+	Guard string        `yaml:"guard,omitempty" json:"guard,omitempty"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+type PermissionDefinition struct {
+	State types.TxState `yaml:"state,omitempty" json:"state,omitempty"`
+	Event types.TxEvent `yaml:"event" json:"event"`
+	Roles []string      `yaml:"roles" json:"roles"`
+
+	Line int `yaml:"-" json:"-"`
+}
+
+// WorkflowFileError is an error found at Line of a workflow file.
+type WorkflowFileError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *WorkflowFileError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *WorkflowFileError) Unwrap() error {
+	return e.Err
+}
+
+// LoadWorkflowFile reads the workflow file at path and builds a Workflow from it.
+func LoadWorkflowFile(path string, opts ...WorkflowOption) (*Workflow, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ParseWorkflowDefinition(path, b)
+	if err != nil {
+		return nil, err
+	}
+	return d.Build(opts...)
+}
+
+// LoadWorkflow reads a workflow file from r and builds a Workflow from it.
+func LoadWorkflow(r io.Reader, opts ...WorkflowOption) (*Workflow, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ParseWorkflowDefinition("", b)
+	if err != nil {
+		return nil, err
+	}
+	return d.Build(opts...)
+}
+
+// Guards returns the guard names used by the transitions of d.
+func (d *WorkflowDefinition) Guards() []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, t := range d.Transitions {
+		if t.Guard != "" && !seen[t.Guard] {
+			seen[t.Guard] = true
+			res = append(res, t.Guard)
+		}
+	}
+	return res
+}
+
+// Build returns the Workflow defined by d. Guards used by d must be registered in opts.
+// Events and permissions given in opts are added to those of d.
+// The workflow must pass Workflow.Validate, and each issue is reported at its line.
+func (d *WorkflowDefinition) Build(opts ...WorkflowOption) (*Workflow, error) {
+	var o workflowOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, t := range d.Transitions {
+		if _, ok := o.guards[t.Guard]; t.Guard != "" && !ok {
+			return nil, d.errorf(t.Line, "guard is not registered: %s", t.Guard)
+		}
+	}
+
+	states := make([]types.TxStateSpec, 0, len(d.States))
+	for _, s := range d.States {
+		states = append(states, types.TxStateSpec{Name: s.Name, Category: s.Category, Terminal: s.Terminal})
+	}
+	declared := make(map[types.TxEvent]bool, len(o.events))
+	for _, ev := range o.events {
+		declared[ev] = true
+	}
+	events := make([]types.TxEvent, 0, len(d.Events))
+	for _, ev := range d.Events {
+		if !declared[ev.Name] {
+			events = append(events, ev.Name)
+		}
+	}
+	transitions := make([]types.TxTransition, 0, len(d.Transitions))
+	for _, t := range d.Transitions {
+		transitions = append(transitions, types.TxTransition{From: t.From, Event: t.Event, To: t.To, Guard: t.Guard})
+	}
+
+	perms := make([]Permission, 0, len(d.Permissions))
+	for _, p := range d.Permissions {
+		perms = append(perms, Permission{State: p.State, Event: p.Event, Roles: p.Roles})
+	}
+
+	opts = append(append([]WorkflowOption(nil), opts...), WithEvents(events...), WithPermissions(perms...))
+	w, err := NewWorkflow(d.Name, d.Initial, states, transitions, opts...)
+	if err != nil {
+		return nil, d.errorf(d.Line, "%w", err)
+	}
+
+	var verr *WorkflowValidationError
+	if err := w.Validate(); errors.As(err, &verr) {
+		errs := make([]error, 0, len(verr.Issues))
+		for _, v := range verr.Issues {
+			errs = append(errs, d.errorf(d.issueLine(v), "%s", v.Message))
+		}
+		return nil, errors.Join(errs...)
+	}
+	return w, nil
+}
+
+// issueLine returns the line of the transition or state v is about.
+func (d *WorkflowDefinition) issueLine(v WorkflowIssue) int {
+	if v.Event != "" {
+		for _, t := range d.Transitions {
+			if t.From == v.State && t.Event == v.Event {
+				return t.Line
+			}
+		}
+	}
+	for _, s := range d.States {
+		if s.Name == v.State {
+			return s.Line
+		}
+	}
+	return d.Line
+}
+
+// ParseWorkflowDefinition parses a workflow file and checks that every name it refers to is declared.
+// file is only used in error messages.
+func ParseWorkflowDefinition(file string, b []byte) (*WorkflowDefinition, error) {
+	d := &WorkflowDefinition{File: file, Line: 1}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, d.errorf(yamlErrorLine(err), "%s", yamlErrorPattern.ReplaceAllString(err.Error(), ""))
+	}
+	if len(doc.Content) == 0 {
+		return nil, d.errorf(1, "workflow definition is empty")
+	}
+
+	if err := d.decode(doc.Content[0]); err != nil {
+		return nil, err
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+var (
+	yamlErrorPattern     = regexp.MustCompile(`^yaml: (line \d+: )?`)
+	yamlErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+)
+
+func yamlErrorLine(err error) int {
+	m := yamlErrorLinePattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 1
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
+
+func (d *WorkflowDefinition) errorf(line int, format string, args ...any) error {
+	return &WorkflowFileError{File: d.File, Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+func (d *WorkflowDefinition) decode(n *yaml.Node) error {
+	d.Line = n.Line
+	fields, err := d.mapping(n, []string{"name", "initial", "states", "events", "transitions", "permissions"}, []string{"name", "initial", "states", "events", "transitions"})
+	if err != nil {
+		return err
+	}
+
+	if d.Name, err = d.scalar(fields["name"]); err != nil {
+		return err
+	}
+	initial, err := d.scalar(fields["initial"])
+	if err != nil {
+		return err
+	}
+	d.Initial = types.TxState(initial)
+
+	states, err := d.sequence(fields["states"])
+	if err != nil {
+		return err
+	}
+	for _, sn := range states {
+		f, err := d.mapping(sn, []string{"name", "category", "terminal"}, []string{"name"})
+		if err != nil {
+			return err
+		}
+		s := StateDefinition{Line: sn.Line}
+		name, err := d.scalar(f["name"])
+		if err != nil {
+			return err
+		}
+		s.Name = types.TxState(name)
+		if f["category"] != nil {
+			category, err := d.scalar(f["category"])
+			if err != nil {
+				return err
+			}
+			s.Category = types.TxCategory(category)
+		}
+		if f["terminal"] != nil {
+			if err := f["terminal"].Decode(&s.Terminal); err != nil {
+				return d.errorf(f["terminal"].Line, "terminal must be true or false")
+			}
+		}
+		d.States = append(d.States, s)
+	}
+
+	events, err := d.sequence(fields["events"])
+	if err != nil {
+		return err
+	}
+	for _, en := range events {
+		ev := EventDefinition{Line: en.Line}
+		if en.Kind == yaml.ScalarNode {
+			name, err := d.scalar(en)
+			if err != nil {
+				return err
+			}
+			ev.Name = types.TxEvent(name)
+		} else {
+			f, err := d.mapping(en, []string{"name", "description"}, []string{"name"})
+			if err != nil {
+				return err
+			}
+			name, err := d.scalar(f["name"])
+			if err != nil {
+				return err
+			}
+			ev.Name = types.TxEvent(name)
+			if f["description"] != nil {
+				if ev.Description, err = d.scalar(f["description"]); err != nil {
+					return err
+				}
+			}
+		}
+		d.Events = append(d.Events, ev)
+	}
+
+	transitions, err := d.sequence(fields["transitions"])
+	if err != nil {
+		return err
+	}
+	for _, tn := range transitions {
+		f, err := d.mapping(tn, []string{"from", "event", "to", "guard"}, []string{"from", "event", "to"})
+		if err != nil {
+			return err
+		}
+		t := TransitionDefinition{Line: tn.Line}
+		values := make(map[string]string)
+		for _, key := range []string{"from", "event", "to", "guard"} {
+			if f[key] == nil {
+				continue
+			}
+			if values[key], err = d.scalar(f[key]); err != nil {
+				return err
+			}
+		}
+		t.From = types.TxState(values["from"])
+		t.Event = types.TxEvent(values["event"])
+		t.To = types.TxState(values["to"])
+		t.Guard = values["guard"]
+		d.Transitions = append(d.Transitions, t)
+	}
+
+	if fields["permissions"] == nil {
+		return nil
+	}
+	permissions, err := d.sequence(fields["permissions"])
+	if err != nil {
+		return err
+	}
+	for _, pn := range permissions {
+		f, err := d.mapping(pn, []string{"state", "event", "roles"}, []string{"event", "roles"})
+		if err != nil {
+			return err
+		}
+		p := PermissionDefinition{Line: pn.Line}
+		if f["state"] != nil {
+			state, err := d.scalar(f["state"])
+			if err != nil {
+				return err
+			}
+			p.State = types.TxState(state)
+		}
+		event, err := d.scalar(f["event"])
+		if err != nil {
+			return err
+		}
+		p.Event = types.TxEvent(event)
+		roles, err := d.sequence(f["roles"])
+		if err != nil {
+			return err
+		}
+		for _, rn := range roles {
+			role, err := d.scalar(rn)
+			if err != nil {
+				return err
+			}
+			p.Roles = append(p.Roles, role)
+		}
+		d.Permissions = append(d.Permissions, p)
+	}
+	return nil
+}
+
+// mapping returns the values of n by key after rejecting unknown and missing keys.
+func (d *WorkflowDefinition) mapping(n *yaml.Node, known, required []string) (map[string]*yaml.Node, error) {
+	if n.Kind != yaml.MappingNode {
+		return nil, d.errorf(n.Line, "expected a mapping")
+	}
+	allowed := make(map[string]bool)
+	for _, k := range known {
+		allowed[k] = true
+	}
+
+	res := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if !allowed[k.Value] {
+			return nil, d.errorf(k.Line, "unknown key: %s", k.Value)
+		}
+		res[k.Value] = v
+	}
+	for _, k := range required {
+		if _, ok := res[k]; !ok {
+			return nil, d.errorf(n.Line, "missing key: %s", k)
+		}
+	}
+	return res, nil
+}
+
+func (d *WorkflowDefinition) sequence(n *yaml.Node) ([]*yaml.Node, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, d.errorf(n.Line, "expected a list")
+	}
+	return n.Content, nil
+}
+
+func (d *WorkflowDefinition) scalar(n *yaml.Node) (string, error) {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return "", d.errorf(n.Line, "expected a string")
+	}
+	return n.Value, nil
+}
+
+// check reports references to undeclared names at the line they appear.
+func (d *WorkflowDefinition) check() error {
+	if d.Name == "" {
+		return d.errorf(d.Line, "name must not be empty")
+	}
+	if len(d.States) == 0 {
+		return d.errorf(d.Line, "at least one state is required")
+	}
+
+	states := make(map[types.TxState]int)
+	for _, s := range d.States {
+		if s.Name == "" {
+			return d.errorf(s.Line, "state name must not be empty")
+		}
+		if line, ok := states[s.Name]; ok {
+			return d.errorf(s.Line, "state %s is already declared at line %d", s.Name, line)
+		}
+		states[s.Name] = s.Line
+	}
+	if _, ok := states[d.Initial]; !ok {
+		return d.errorf(d.Line, "initial state is not declared: %q", d.Initial)
+	}
+
+	events := make(map[types.TxEvent]int)
+	for _, ev := range d.Events {
+		if ev.Name == "" {
+			return d.errorf(ev.Line, "event name must not be empty")
+		}
+		if line, ok := events[ev.Name]; ok {
+			return d.errorf(ev.Line, "event %s is already declared at line %d", ev.Name, line)
+		}
+		events[ev.Name] = ev.Line
+	}
+
+	type key struct {
+		from types.TxState
+		ev   types.TxEvent
+	}
+	seen := make(map[key]TransitionDefinition)
+	for _, t := range d.Transitions {
+		if _, ok := states[t.From]; !ok && t.From != "" {
+			return d.errorf(t.Line, "state is not declared: %s", t.From)
+		}
+		if _, ok := events[t.Event]; !ok {
+			return d.errorf(t.Line, "event is not declared: %s", t.Event)
+		}
+		if _, ok := states[t.To]; !ok {
+			return d.errorf(t.Line, "state is not declared: %s", t.To)
+		}
+		k := key{t.From, t.Event}
+		if v, ok := seen[k]; ok {
+			return d.errorf(t.Line, "%s from %q already leads to %s at line %d", t.Event, t.From, v.To, v.Line)
+		}
+		seen[k] = t
+	}
+
+	for _, p := range d.Permissions {
+		if _, ok := states[p.State]; !ok && p.State != "" {
+			return d.errorf(p.Line, "state is not declared: %s", p.State)
+		}
+		if _, ok := events[p.Event]; !ok {
+			return d.errorf(p.Line, "event is not declared: %s", p.Event)
+		}
+		if len(p.Roles) == 0 {
+			return d.errorf(p.Line, "at least one role is required")
+		}
+	}
+	return nil
+}
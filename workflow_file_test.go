@@ -0,0 +1,167 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_LoadWorkflowFile(t *testing.T) {
+	approved := false
+	w, err := LoadWorkflowFile("testdata/ticket.yaml", WithGuard("approved", func(types.TxTransition) error {
+		if !approved {
+			return errors.New("not approved")
+		}
+		return nil
+	}))
+	require.Nil(t, err)
+	require.EqualValues(t, "ticket", w.Name())
+	require.EqualValues(t, "open", w.Initial())
+	require.EqualValues(t, []types.TxEvent{"submit", "close"}, w.Events())
+	require.Len(t, w.States(), 3)
+	require.True(t, w.States()[2].Terminal)
+
+	e := &TxStateMachineClock{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.InitSm(""))
+	require.True(t, e.IsCategorySm(types.PendingTxCategory))
+	require.EqualValues(t, "open", e.State)
+
+	require.Nil(t, e.FireSm("submit"))
+	require.EqualValues(t, "review", e.State)
+	require.EqualValues(t, 1, e.Version)
+
+	require.NotNil(t, e.FireSm("close"))
+	require.EqualValues(t, "review", e.State)
+
+	approved = true
+	require.Nil(t, e.FireSm("close"))
+	require.EqualValues(t, "closed", e.State)
+	require.True(t, e.IsCategorySm(types.ClosedTxCategory))
+	require.False(t, e.IsPendingKindSm())
+
+	require.NotNil(t, e.SetWorkflowSm(DefaultWorkflow()))
+}
+
+func Test_LoadWorkflow_json(t *testing.T) {
+	w, err := LoadWorkflow(strings.NewReader(`{
+	"name": "door",
+	"initial": "closed",
+	"states": [{"name": "closed"}, {"name": "opened"}],
+	"events": ["open", "close"],
+	"transitions": [
+		{"from": "closed", "event": "open", "to": "opened"},
+		{"from": "opened", "event": "close", "to": "closed"}
+	]
+}`))
+	require.Nil(t, err)
+	require.Len(t, w.Transitions(), 2)
+
+	e := &TxStateMachine{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.ErrorIs(t, e.FireSm("open"), ErrUninitialized)
+	require.Nil(t, e.InitSm(""))
+	require.Nil(t, e.FireSm("open"))
+	require.EqualValues(t, "opened", e.State)
+	require.NotNil(t, e.FireSm("open"))
+}
+
+func Test_WorkflowDefinition_Build_merges_options(t *testing.T) {
+	d, err := ParseWorkflowDefinition("door.json", []byte(`{
+	"name": "door",
+	"initial": "closed",
+	"states": [{"name": "closed"}, {"name": "opened"}],
+	"events": ["open", "close"],
+	"transitions": [
+		{"from": "closed", "event": "open", "to": "opened"},
+		{"from": "opened", "event": "close", "to": "closed"}
+	],
+	"permissions": [{"event": "open", "roles": ["keeper"]}]
+}`))
+	require.Nil(t, err)
+
+	opts := make([]WorkflowOption, 0, 8)
+	opts = append(opts, WithEvents("close", "lock"), WithPermissions(Permission{Event: "close", Roles: []string{"keeper"}}),
+		WithRoles(func(r AuthRequest) []string {
+			if r.Actor == "alice" {
+				return []string{"keeper"}
+			}
+			return nil
+		}))
+	w, err := d.Build(opts...)
+	require.Nil(t, err)
+	// the options of the caller are not written to
+	require.Nil(t, opts[:cap(opts)][len(opts)])
+	require.EqualValues(t, []types.TxEvent{"close", "lock", "open"}, w.Events())
+
+	e := &TxStateMachine{}
+	require.Nil(t, e.SetWorkflowSm(w))
+	require.Nil(t, e.InitSm(""))
+	require.ErrorIs(t, e.FireSm("open"), ErrForbidden)
+	e.SetActorSm("alice")
+	require.Nil(t, e.FireSm("open"))
+	require.ErrorIs(t, e.FireSm("close"), ErrForbidden)
+	e.SetActorSm("alice")
+	require.Nil(t, e.FireSm("close"))
+}
+
+func Test_LoadWorkflowFile_guard_not_registered(t *testing.T) {
+	_, err := LoadWorkflowFile("testdata/ticket.yaml")
+	var fe *WorkflowFileError
+	require.True(t, errors.As(err, &fe))
+	require.EqualValues(t, 19, fe.Line)
+	require.EqualValues(t, "testdata/ticket.yaml:19: guard is not registered: approved", err.Error())
+}
+
+func Test_ParseWorkflowDefinition_errors(t *testing.T) {
+	cases := []struct {
+		doc  string
+		line int
+		msg  string
+	}{
+		{"", 1, "workflow definition is empty"},
+		{"name: [", 1, "did not find expected node content"},
+		{"name: w\ninitial: a\nstates: []\nevents: []\n", 1, "missing key: transitions"},
+		{"name: w\ninitial: a\nstates:\n  - name: a\n    color: red\nevents: []\ntransitions: []\n", 5, "unknown key: color"},
+		{"name: w\ninitial: b\nstates:\n  - name: a\nevents: []\ntransitions: []\n", 1, `initial state is not declared: "b"`},
+		{"name: w\ninitial: a\nstates:\n  - name: a\n  - name: a\nevents: []\ntransitions: []\n", 5, "state a is already declared at line 4"},
+		{"name: w\ninitial: a\nstates:\n  - name: a\n    terminal: maybe\nevents: []\ntransitions: []\n", 5, "terminal must be true or false"},
+		{"name: w\ninitial: a\nstates:\n  - name: a\nevents: [go]\ntransitions:\n  - from: a\n    event: stop\n    to: a\n", 7, "event is not declared: stop"},
+		{"name: w\ninitial: a\nstates:\n  - name: a\nevents: [go]\ntransitions:\n  - from: a\n    event: go\n    to: b\n", 7, "state is not declared: b"},
+		{"name: w\ninitial: a\nstates:\n  - name: a\n  - name: b\nevents: [go]\ntransitions:\n  - {from: a, event: go, to: b}\n  - {from: a, event: go, to: a}\n", 9, `go from "a" already leads to b at line 8`},
+		{"name: w\ninitial: a\nstates: a\nevents: []\ntransitions: []\n", 3, "expected a list"},
+	}
+	for _, c := range cases {
+		_, err := ParseWorkflowDefinition("", []byte(c.doc))
+		var fe *WorkflowFileError
+		require.True(t, errors.As(err, &fe), c.doc)
+		require.EqualValues(t, c.line, fe.Line, c.doc)
+		require.Contains(t, fe.Err.Error(), c.msg, c.doc)
+	}
+}
+
+func Test_LoadWorkflow_validate(t *testing.T) {
+	_, err := LoadWorkflow(strings.NewReader(`name: w
+initial: open
+states:
+  - name: open
+  - name: stuck
+  - name: closed
+    terminal: true
+events: [close, reopen]
+transitions:
+  - from: open
+    event: close
+    to: closed
+  - from: closed
+    event: reopen
+    to: open
+`))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "line 5: stuck is unreachable from open")
+	require.Contains(t, err.Error(), "line 5: no event leaves non-terminal state stuck")
+	require.Contains(t, err.Error(), "line 13: terminal state closed has a transition on reopen to open")
+}
@@ -0,0 +1,169 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/state/types"
+)
+
+func Test_DefaultWorkflow_ExportDOT(t *testing.T) {
+	dot := DefaultWorkflow().ExportDOT()
+	require.True(t, strings.HasPrefix(dot, `digraph "tx" {`))
+	require.Contains(t, dot, `"__start__" -> "pending" [label="pending"];`)
+	require.Contains(t, dot, `"pending" -> "active" [label="approve"];`)
+	require.Contains(t, dot, `"pending" -> "pending" [label="modify_pending"];`)
+	require.Contains(t, dot, `"remove_pending" -> "removed" [label="approve"];`)
+	require.Contains(t, dot, `"canceled" [peripheries=2];`)
+	require.Contains(t, dot, `"removed" [peripheries=2];`)
+	require.NotContains(t, dot, `"canceled" ->`)
+	require.Equal(t, len(DefaultWorkflow().Transitions()), strings.Count(dot, "->"))
+}
+
+func Test_DefaultWorkflow_ExportMermaid(t *testing.T) {
+	m := DefaultWorkflow().ExportMermaid()
+	require.True(t, strings.HasPrefix(m, "stateDiagram-v2\n"))
+	require.Contains(t, m, "    [*] --> pending: pending\n")
+	require.Contains(t, m, "    active_pending --> inactive: cancel\n")
+	require.Contains(t, m, "    canceled --> [*]\n")
+	require.Contains(t, m, "    removed --> [*]\n")
+}
+
+func Test_DefaultWorkflow_ExportPlantUML(t *testing.T) {
+	p := DefaultWorkflow().ExportPlantUML()
+	require.True(t, strings.HasPrefix(p, "@startuml\n"))
+	require.True(t, strings.HasSuffix(p, "@enduml\n"))
+	require.Contains(t, p, "[*] --> pending : pending\n")
+	require.Contains(t, p, "inactive --> active_pending : active_pending\n")
+	require.Contains(t, p, "removed --> [*]\n")
+}
+
+func Test_Workflow_Export_guard(t *testing.T) {
+	w, err := NewWorkflow("ticket", "open",
+		[]types.TxStateSpec{{Name: "open"}, {Name: "in-review"}, {Name: "closed", Terminal: true}},
+		[]types.TxTransition{
+			{From: "open", Event: "submit", To: "in-review"},
+			{From: "in-review", Event: "close", To: "closed", Guard: "approved"},
+		},
+		WithGuard("approved", func(types.TxTransition) error { return nil }),
+	)
+	require.Nil(t, err)
+
+	require.Contains(t, w.ExportDOT(), `"__start__" -> "open";`)
+	require.Contains(t, w.ExportDOT(), `"in-review" -> "closed" [label="close [approved]", style=dashed];`)
+	require.Contains(t, w.ExportMermaid(), `    state "in-review" as in_review`)
+	require.Contains(t, w.ExportMermaid(), "    in_review --> closed: close [approved]\n")
+	require.Contains(t, w.ExportPlantUML(), "[*] --> open\n")
+}
+
+func Test_NewWorkflow_invalid(t *testing.T) {
+	states := []types.TxStateSpec{{Name: "open"}, {Name: "closed"}}
+
+	_, err := NewWorkflow("w", "unknown", states, nil)
+	require.NotNil(t, err)
+
+	_, err = NewWorkflow("w", "open", states, []types.TxTransition{{From: "open", Event: "close", To: "gone"}})
+	require.NotNil(t, err)
+
+	_, err = NewWorkflow("w", "open", states, []types.TxTransition{{From: "open", Event: "close", To: "closed", Guard: "missing"}})
+	require.NotNil(t, err)
+
+	_, err = NewWorkflow("w", "open", states, []types.TxTransition{
+		{From: "open", Event: "close", To: "closed"},
+		{From: "open", Event: "close", To: "open"},
+	})
+	require.NotNil(t, err)
+
+	w, err := NewWorkflow("w", "open", states, []types.TxTransition{{From: "open", Event: "close", To: "closed", Guard: "never"}},
+		WithGuard("never", func(types.TxTransition) error { return errors.New("never") }))
+	require.Nil(t, err)
+	require.EqualValues(t, "open", w.Initial())
+}
+
+func Test_TxStateMachineClock_follows_loaded_state(t *testing.T) {
+	e := &TxStateMachineClock{State: types.RemovedTxState}
+	require.NotNil(t, e.RemovePendingSm())
+	require.EqualValues(t, types.RemovedTxState, e.State)
+
+	// State assigned directly wins over the state machine
+	e.State = types.ActiveTxState
+	require.Nil(t, e.RemovePendingSm())
+	require.EqualValues(t, types.RemovePendingTxState, e.State)
+
+	e.State = types.TxState("unknown")
+	require.NotNil(t, e.ApproveSm())
+}
+
+func Test_TxStateMachine_follows_loaded_state(t *testing.T) {
+	e := &TxStateMachine{State: types.ActiveTxState}
+	require.NotNil(t, e.PendingSm())
+	require.EqualValues(t, types.ActiveTxState, e.State)
+
+	e = &TxStateMachine{}
+	require.ErrorIs(t, e.ModifyPendingSm(), ErrUninitialized)
+	require.EqualValues(t, "", e.State)
+	require.Nil(t, e.PendingSm())
+	require.Nil(t, e.ModifyPendingSm())
+	require.EqualValues(t, types.PendingTxState, e.State)
+}
+
+func Test_DefaultWorkflow_Validate(t *testing.T) {
+	require.Nil(t, DefaultWorkflow().Validate())
+}
+
+func Test_Workflow_Validate(t *testing.T) {
+	w, err := NewWorkflow("broken", types.PendingTxState,
+		[]types.TxStateSpec{
+			{Name: types.PendingTxState},
+			{Name: types.ActiveTxState},
+			{Name: types.CanceledTxState, Terminal: true},
+			{Name: types.InactiveTxState},
+			{Name: types.RemovedTxState, Terminal: true},
+		},
+		[]types.TxTransition{
+			{From: types.PendingTxState, Event: types.ApproveTxEvent, To: types.ActiveTxState},
+			{From: types.PendingTxState, Event: types.CancelTxEvent, To: types.CanceledTxState},
+			// self-loops on terminal states are never used
+			{From: types.CanceledTxState, Event: types.ApproveTxEvent, To: types.CanceledTxState},
+			{From: types.CanceledTxState, Event: types.CancelTxEvent, To: types.CanceledTxState},
+			{From: types.ActiveTxState, Event: types.ModifyPendingTxEvent, To: types.ActiveTxState},
+			{From: types.InactiveTxState, Event: types.ActivePendingTxEvent, To: types.ActiveTxState},
+		})
+	require.Nil(t, err)
+
+	err = w.Validate()
+	var verr *WorkflowValidationError
+	require.True(t, errors.As(err, &verr))
+
+	kinds := map[WorkflowIssueKind][]types.TxState{}
+	for _, v := range verr.Issues {
+		kinds[v.Kind] = append(kinds[v.Kind], v.State)
+	}
+	require.EqualValues(t, []types.TxState{types.CanceledTxState, types.CanceledTxState}, kinds[TerminalExitIssue])
+	require.EqualValues(t, []types.TxState{types.ActiveTxState}, kinds[DeadEndStateIssue])
+	require.EqualValues(t, []types.TxState{types.InactiveTxState, types.RemovedTxState}, kinds[UnreachableStateIssue])
+	require.Contains(t, err.Error(), "terminal state canceled has a transition on approve to canceled")
+}
+
+func Test_NewWorkflow_ambiguous(t *testing.T) {
+	_, err := NewWorkflow("w", "a",
+		[]types.TxStateSpec{{Name: "a"}, {Name: "b"}},
+		[]types.TxTransition{{From: "a", Event: "go", To: "b"}, {From: "a", Event: "go", To: "a"}})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `go from "a" leads to both b and a`)
+
+	d := &WorkflowDefinition{
+		Name: "w", Initial: "a", Line: 1,
+		States: []StateDefinition{{Name: "a"}, {Name: "b"}},
+		Events: []EventDefinition{{Name: "go"}},
+		Transitions: []TransitionDefinition{
+			{From: "a", Event: "go", To: "b", Line: 8},
+			{From: "a", Event: "go", To: "a", Line: 9},
+		},
+	}
+	_, err = d.Build()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `go from "a" leads to both b and a`)
+}
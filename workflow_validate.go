@@ -0,0 +1,112 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wonksing/state/types"
+)
+
+type WorkflowIssueKind string
+
+const (
+	// UnreachableStateIssue is a state no sequence of events leads to from the initial state.
+	UnreachableStateIssue WorkflowIssueKind = "unreachable_state"
+	// DeadEndStateIssue is a non-terminal state no event leaves.
+	DeadEndStateIssue WorkflowIssueKind = "dead_end_state"
+	// TerminalExitIssue is a transition declared on a terminal state, including a self-loop.
+	TerminalExitIssue WorkflowIssueKind = "terminal_exit"
+)
+
+// WorkflowIssue is a problem found by Workflow.Validate.
+type WorkflowIssue struct {
+	Kind    WorkflowIssueKind
+	State   types.TxState
+	Event   types.TxEvent
+	Message string
+}
+
+// WorkflowValidationError holds every issue of a workflow.
+type WorkflowValidationError struct {
+	Workflow string
+	Issues   []WorkflowIssue
+}
+
+func (e *WorkflowValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, v := range e.Issues {
+		msgs = append(msgs, v.Message)
+	}
+	return fmt.Sprintf("workflow %s is invalid: %s", e.Workflow, strings.Join(msgs, "; "))
+}
+
+// Validate checks w for states unreachable from the initial state, non-terminal states no event leaves
+// and terminal states with transitions. Events leading to two states are rejected when w is built, see NewWorkflow.
+// It returns a *WorkflowValidationError listing every issue, or nil.
+func (w *Workflow) Validate() error {
+	var issues []WorkflowIssue
+
+	reachable := w.reachable()
+	for _, s := range w.table.States {
+		if !reachable[s.Name] {
+			issues = append(issues, WorkflowIssue{
+				Kind: UnreachableStateIssue, State: s.Name,
+				Message: fmt.Sprintf("%s is unreachable from %s", s.Name, w.Initial()),
+			})
+		}
+
+		outgoing := w.table.Outgoing(s.Name)
+		if s.Terminal {
+			for _, t := range outgoing {
+				issues = append(issues, WorkflowIssue{
+					Kind: TerminalExitIssue, State: s.Name, Event: t.Event,
+					Message: fmt.Sprintf("terminal state %s has a transition on %s to %s", s.Name, t.Event, t.To),
+				})
+			}
+			continue
+		}
+
+		leaves := false
+		for _, t := range outgoing {
+			if t.To != s.Name {
+				leaves = true
+				break
+			}
+		}
+		if !leaves {
+			issues = append(issues, WorkflowIssue{
+				Kind: DeadEndStateIssue, State: s.Name,
+				Message: fmt.Sprintf("no event leaves non-terminal state %s", s.Name),
+			})
+		}
+	}
+
+	if len(issues) > 0 {
+		return &WorkflowValidationError{Workflow: w.Name(), Issues: issues}
+	}
+	return nil
+}
+
+// reachable returns the states an entity may get to from the initial state or from no state.
+func (w *Workflow) reachable() map[types.TxState]bool {
+	seen := map[types.TxState]bool{w.Initial(): true}
+	queue := []types.TxState{w.Initial()}
+	for _, t := range w.table.Outgoing("") {
+		if !seen[t.To] {
+			seen[t.To] = true
+			queue = append(queue, t.To)
+		}
+	}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, t := range w.table.Outgoing(s) {
+			if !seen[t.To] {
+				seen[t.To] = true
+				queue = append(queue, t.To)
+			}
+		}
+	}
+	return seen
+}